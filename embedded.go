@@ -0,0 +1,36 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io/fs"
+
+	"golang.org/x/mod/modfile"
+)
+
+//go:generate go run mkzip.go
+
+// templateZip is a copy of the template module, made by mkzip.go,
+// for scaffolding without downloading the template.
+//
+//go:embed template.zip
+var templateZip []byte
+
+// embeddedTemplate returns the copy of the template embedded in the tool.
+func embeddedTemplate() (*source, error) {
+	zr, err := zip.NewReader(bytes.NewReader(templateZip), int64(len(templateZip)))
+	if err != nil {
+		return nil, fmt.Errorf("reading embedded template: %v", err)
+	}
+	data, err := fs.ReadFile(zr, "go.mod")
+	if err != nil {
+		return nil, fmt.Errorf("reading embedded template: %v", err)
+	}
+	mod := modfile.ModulePath(data)
+	if mod == "" {
+		return nil, fmt.Errorf("reading embedded template: go.mod has no module statement")
+	}
+	return &source{mod: mod, fsys: zr}, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEmbeddedTemplate checks that template.zip is up to date with the
+// template directory, as written by go generate.
+func TestEmbeddedTemplate(t *testing.T) {
+	zr, err := zip.NewReader(bytes.NewReader(templateZip), int64(len(templateZip)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipped := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		zipped[f.Name] = f
+	}
+
+	err = filepath.WalkDir("template", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel("template", path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		zf := zipped[name]
+		if zf == nil {
+			t.Errorf("%s is missing from template.zip", name)
+			return nil
+		}
+		delete(zipped, name)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var want []byte
+		if d.Type()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			want = []byte(filepath.ToSlash(target))
+			if zf.Mode()&fs.ModeSymlink == 0 {
+				t.Errorf("%s is a symbolic link, but not in template.zip", name)
+			}
+		} else {
+			if want, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			if exec, zexec := info.Mode()&0111 != 0, zf.Mode()&0111 != 0; exec != zexec {
+				t.Errorf("%s is executable = %v, but %v in template.zip", name, exec, zexec)
+			}
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		got, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs in template.zip", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range zipped {
+		t.Errorf("%s is in template.zip, but not in template", name)
+	}
+	if t.Failed() {
+		t.Log("template.zip is out of date; run go generate")
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+
+	"github.com/t-0-network/provider-starter-go/internal/edit"
+)
+
+// features are the optional parts of the template, selected with -features.
+var features = []struct {
+	name string
+	doc  string
+}{
+	{"payout", "PayOut handler and published pay-out quotes"},
+	{"payin", "UpdatePayment handler and published pay-in quotes"},
+	{"quotes", "GetQuote probe of the quotes published in the network"},
+	{"ledger", "AppendLedgerEntries handler"},
+	{"limits", "UpdateLimit handler"},
+	{"aml", "ApprovePaymentQuotes handler, for a last look after AML checks"},
+}
+
+func isFeature(name string) bool {
+	return slices.ContainsFunc(features, func(f struct{ name, doc string }) bool { return f.name == name })
+}
+
+// parseFeatures parses the -features list of feature names,
+// which may be "all", and returns the selected features
+// as a comma-separated list in the order of features.
+func parseFeatures(list string) (string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if name != "all" && !isFeature(name) {
+			return "", fmt.Errorf("unknown feature %q", name)
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no features selected")
+	}
+	var selected []string
+	for _, f := range features {
+		if slices.Contains(names, f.name) || slices.Contains(names, "all") {
+			selected = append(selected, f.name)
+		}
+	}
+	return strings.Join(selected, ","), nil
+}
+
+// hasFeature reports whether the new module includes the named feature.
+func (sc *scaffold) hasFeature(name string) bool {
+	return slices.Contains(strings.Split(sc.vars["Features"], ","), name)
+}
+
+// selectFeatures removes the parts of the template file named file,
+// with contents data, belonging to features for which has returns false,
+// along with all feature annotations. It reports whether to keep the
+// file at all.
+//
+// The annotations are comment lines, starting with // or #:
+//
+//	starter:feature name...       keep the file only for one of the features
+//	starter:begin name...         keep the following lines only for one of the features,
+//	starter:end                   up to here
+//
+// In a Go file from which code was removed, selectFeatures also removes
+// the imports left unused, and formats the result.
+func selectFeatures(data []byte, file string, has func(string) bool) ([]byte, bool, error) {
+	if !bytes.Contains(data, []byte("starter:")) {
+		return data, true, nil
+	}
+
+	anyOf := func(lineno int, names []string) (bool, error) {
+		if len(names) == 0 {
+			return false, fmt.Errorf("%s:%d: missing feature name", file, lineno)
+		}
+		ok := false
+		for _, name := range names {
+			if !isFeature(name) {
+				return false, fmt.Errorf("%s:%d: unknown feature %q", file, lineno, name)
+			}
+			ok = ok || has(name)
+		}
+		return ok, nil
+	}
+
+	orig := data
+	var out []string
+	keepFile := true
+	removed := false // code was removed
+	dropped := false // a line was dropped since the last line kept
+	inRegion := 0    // line number of the starter:begin of the current region, if any
+	keepRegion := true
+	lines := strings.SplitAfter(string(data), "\n")
+	for i, line := range lines {
+		lineno := i + 1
+		directive, ok := annotation(line)
+		switch {
+		case !ok:
+			if !keepRegion {
+				removed = true
+				dropped = true
+				continue
+			}
+			// Do not leave two blank lines where an annotation or region was.
+			if dropped && strings.TrimSpace(line) == "" && (len(out) == 0 || strings.TrimSpace(out[len(out)-1]) == "") {
+				continue
+			}
+			out = append(out, line)
+			dropped = false
+			continue
+		case directive[0] == "starter:feature":
+			keep, err := anyOf(lineno, directive[1:])
+			if err != nil {
+				return nil, false, err
+			}
+			keepFile = keepFile && keep
+		case directive[0] == "starter:begin":
+			if inRegion != 0 {
+				return nil, false, fmt.Errorf("%s:%d: starter:begin inside region begun at line %d", file, lineno, inRegion)
+			}
+			keep, err := anyOf(lineno, directive[1:])
+			if err != nil {
+				return nil, false, err
+			}
+			inRegion, keepRegion = lineno, keep
+		case directive[0] == "starter:end":
+			if inRegion == 0 {
+				return nil, false, fmt.Errorf("%s:%d: starter:end without starter:begin", file, lineno)
+			}
+			inRegion, keepRegion = 0, true
+		default:
+			return nil, false, fmt.Errorf("%s:%d: unknown annotation %s", file, lineno, directive[0])
+		}
+		dropped = true
+	}
+	if inRegion != 0 {
+		return nil, false, fmt.Errorf("%s:%d: starter:begin without starter:end", file, inRegion)
+	}
+	if !keepFile {
+		return nil, false, nil
+	}
+
+	data = []byte(strings.Join(out, ""))
+	if !strings.HasSuffix(file, ".go") {
+		return data, true, nil
+	}
+	var err error
+	if removed {
+		data, err = removeUnusedImports(orig, data, file)
+	} else {
+		// Dropping annotations may leave blank lines that gofmt would remove.
+		data, err = format.Source(data)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: removing features: %v", file, err)
+	}
+	return data, true, nil
+}
+
+// annotation returns the fields of the feature annotation on line,
+// if it is one.
+func annotation(line string) ([]string, bool) {
+	s := strings.TrimSpace(line)
+	if c, ok := strings.CutPrefix(s, "//"); ok {
+		s = c
+	} else if c, ok := strings.CutPrefix(s, "#"); ok {
+		s = c
+	} else {
+		return nil, false
+	}
+	f := strings.Fields(s)
+	if len(f) == 0 || !strings.HasPrefix(f[0], "starter:") {
+		return nil, false
+	}
+	return f, true
+}
+
+// removeUnusedImports removes the imports of packages that the Go source
+// in old refers to but its edited version data no longer does,
+// and formats the result.
+func removeUnusedImports(old, data []byte, file string) ([]byte, error) {
+	fset := token.NewFileSet()
+	oldf, err := parser.ParseFile(fset, file, old, 0)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parser.ParseFile(fset, file, data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	wasUsed, used := qualifiers(oldf), qualifiers(f)
+
+	buf := edit.NewBuffer(data)
+	// deleteLines deletes the lines holding n.
+	deleteLines := func(n ast.Node) {
+		buf.ReplaceLines(fset.Position(n.Pos()).Line, fset.Position(n.End()).Line, "")
+	}
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.IMPORT {
+			continue
+		}
+		var unused []ast.Spec
+		for _, spec := range d.Specs {
+			spec := spec.(*ast.ImportSpec)
+			if name := importName(spec); wasUsed[name] && !used[name] {
+				unused = append(unused, spec)
+			}
+		}
+		if len(unused) == len(d.Specs) {
+			deleteLines(d)
+			continue
+		}
+		for _, spec := range unused {
+			deleteLines(spec)
+		}
+	}
+	return format.Source(buf.Bytes())
+}
+
+// qualifiers returns the set of package names that f uses
+// to qualify identifiers.
+func qualifiers(f *ast.File) map[string]bool {
+	names := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Obj == nil {
+				names[id.Name] = true
+			}
+		}
+		return true
+	})
+	return names
+}
@@ -0,0 +1,88 @@
+package main
+
+import "testing"
+
+var selectFeaturesTests = []struct {
+	name string
+	file string
+	data string
+	want string // "" if the file is dropped
+	err  bool
+}{
+	{
+		name: "no annotations",
+		file: "a.txt",
+		data: "a\nb\n",
+		want: "a\nb\n",
+	},
+	{
+		name: "file kept",
+		file: "a.txt",
+		data: "# starter:feature payin payout\na\n",
+		want: "a\n",
+	},
+	{
+		name: "file dropped",
+		file: "a.txt",
+		data: "# starter:feature payin\na\n",
+	},
+	{
+		name: "regions",
+		file: "a.txt",
+		data: "a\n\n# starter:begin payin\nb\n# starter:end\n\n# starter:begin payout\nc\n# starter:end\n",
+		want: "a\n\nc\n",
+	},
+	{
+		name: "unused import removed",
+		file: "a.go",
+		data: "package p\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nfunc f() {\n\tos.Exit(0)\n\t// starter:begin quotes\n\tfmt.Println()\n\t// starter:end\n}\n",
+		want: "package p\n\nimport (\n\t\"os\"\n)\n\nfunc f() {\n\tos.Exit(0)\n}\n",
+	},
+	{
+		name: "import declaration removed",
+		file: "a.go",
+		data: "package p\n\nimport \"fmt\"\n\nfunc f() {\n\t// starter:begin quotes\n\tfmt.Println()\n\t// starter:end\n}\n",
+		want: "package p\n\nfunc f() {\n}\n",
+	},
+	{
+		name: "import still used",
+		file: "a.go",
+		data: "package p\n\nimport \"fmt\"\n\nfunc f() {\n\tfmt.Print()\n\t// starter:begin quotes\n\tfmt.Println()\n\t// starter:end\n}\n",
+		want: "package p\n\nimport \"fmt\"\n\nfunc f() {\n\tfmt.Print()\n}\n",
+	},
+	{
+		name: "unknown feature",
+		file: "a.txt",
+		data: "# starter:feature refunds\n",
+		err:  true,
+	},
+	{
+		name: "unterminated region",
+		file: "a.txt",
+		data: "# starter:begin payout\na\n",
+		err:  true,
+	},
+	{
+		name: "end without begin",
+		file: "a.txt",
+		data: "a\n# starter:end\n",
+		err:  true,
+	},
+}
+
+func TestSelectFeatures(t *testing.T) {
+	has := func(name string) bool { return name == "payout" }
+	for _, tt := range selectFeaturesTests {
+		got, keep, err := selectFeatures([]byte(tt.data), tt.file, has)
+		switch {
+		case tt.err:
+			if err == nil {
+				t.Errorf("%s: selectFeatures succeeded, want error", tt.name)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case keep != (tt.want != "") || string(got) != tt.want:
+			t.Errorf("%s: selectFeatures = %q, %v, want %q, %v", tt.name, got, keep, tt.want, tt.want != "")
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// verbatimFile is the template file listing the files to copy unchanged.
+// It is not copied itself.
+const verbatimFile = ".starter-verbatim"
+
+// A verbatimList holds the patterns of the files to copy unchanged.
+type verbatimList []string
+
+// readVerbatim reads the template's .starter-verbatim file, if it has one.
+// Each line holds a pattern, in the syntax of path.Match, naming files to
+// copy into the new module without rendering or rewriting them, such as
+// golden files in testdata directories that must match the output of the
+// code under test byte for byte. A pattern containing a slash is matched
+// against the slash-separated path of the file in the template, or of a
+// directory holding it; any other pattern against each element of the path,
+// so that testdata matches every file in every testdata directory.
+// Blank lines and lines starting with # are ignored.
+func readVerbatim(fsys fs.FS) (verbatimList, error) {
+	data, err := fs.ReadFile(fsys, verbatimFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list verbatimList
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern := strings.Trim(line, "/")
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("%s:%d: invalid pattern %q", verbatimFile, i+1, line)
+		}
+		list = append(list, pattern)
+	}
+	return list, nil
+}
+
+// match reports whether the template file name, a slash-separated path,
+// is to be copied unchanged.
+func (l verbatimList) match(name string) bool {
+	for _, pattern := range l {
+		if strings.Contains(pattern, "/") {
+			for p := name; p != "."; p = path.Dir(p) {
+				if ok, _ := path.Match(pattern, p); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for _, elem := range strings.Split(name, "/") {
+			if ok, _ := path.Match(pattern, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readLink returns the slash-separated target of the symbolic link name
+// in fsys. The target must be a relative path inside fsys, so that the
+// link also works in the new module.
+func readLink(fsys fs.FS, name string) (string, error) {
+	var target string
+	if _, ok := fsys.(fs.ReadLinkFS); ok {
+		t, err := fs.ReadLink(fsys, name)
+		if err != nil {
+			return "", err
+		}
+		target = filepath.ToSlash(t)
+	} else {
+		// In a zip archive, such as the embedded template,
+		// the contents of a symbolic link are its target.
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return "", err
+		}
+		target = string(data)
+	}
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) || !fs.ValidPath(path.Join(path.Dir(name), target)) {
+		return "", fmt.Errorf("%s: symbolic link to %s, outside the template", name, target)
+	}
+	return target, nil
+}
+
+// sniffLen is the number of bytes at the start of a file examined
+// to tell binary files from text, as git does.
+const sniffLen = 8000
+
+// readHead returns the first sniffLen bytes of the file name in fsys,
+// or all of it if it is shorter.
+func readHead(fsys fs.FS, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return head[:n], err
+}
+
+// isTextHead reports whether head, the start of a file returned by readHead,
+// looks like text, as isText does for a whole file.
+func isTextHead(head []byte) bool {
+	if len(head) == sniffLen {
+		// Do not count a character cut in two at the end against the file.
+		for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+			if c := head[len(head)-i]; utf8.RuneStart(c) {
+				if !utf8.FullRune(head[len(head)-i:]) {
+					head = head[:len(head)-i]
+				}
+				break
+			}
+		}
+	}
+	return isText(head)
+}
+
+// open returns a reader for the contents of f in the new module.
+func (f *templateFile) open() (io.ReadCloser, error) {
+	if f.binary {
+		return f.fsys.Open(filepath.ToSlash(f.src))
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+// sameFile reports whether the existing file dst already has the contents
+// of f, and is executable if f is, or is the symbolic link f is.
+// If dst does not exist, the error satisfies errors.Is(err, fs.ErrNotExist).
+func sameFile(dst string, f *templateFile) (bool, error) {
+	fi, err := os.Lstat(dst)
+	if err != nil {
+		return false, err
+	}
+	isLink := fi.Mode()&fs.ModeSymlink != 0
+	switch {
+	case isLink != (f.link != ""):
+		return false, nil
+	case isLink:
+		target, err := os.Readlink(dst)
+		return filepath.ToSlash(target) == f.link, err
+	case !fi.Mode().IsRegular():
+		return false, withKind(errTarget, fmt.Errorf("%s exists and is not a regular file", dst))
+	case f.mode&0111 != 0 && fi.Mode()&0111 == 0:
+		return false, nil // not executable
+	case !f.binary:
+		old, err := os.ReadFile(dst)
+		return bytes.Equal(old, f.data), err
+	}
+	r, err := f.open()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	old, err := os.Open(dst)
+	if err != nil {
+		return false, err
+	}
+	defer old.Close()
+	return sameContents(old, r)
+}
+
+// sameContents reports whether a and b read the same bytes.
+func sameContents(a, b io.Reader) (bool, error) {
+	bufA, bufB := make([]byte, 32<<10), make([]byte, 32<<10)
+	for {
+		na, errA := io.ReadFull(a, bufA)
+		nb, errB := io.ReadFull(b, bufB)
+		for _, err := range []error{errA, errB} {
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return false, err
+			}
+		}
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
+}
+
+// writeFile writes f to the file dst, streaming the contents of a binary
+// file, and making the file executable if f is. An existing symbolic link
+// at dst is replaced, never written through.
+func writeFile(dst string, f *templateFile) error {
+	if fi, err := os.Lstat(dst); err == nil && (f.link != "" || fi.Mode()&fs.ModeSymlink != 0) {
+		if err := os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	if f.link != "" {
+		return os.Symlink(filepath.FromSlash(f.link), dst)
+	}
+
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if f.mode&0111 == 0 {
+		return nil
+	}
+	// An existing file keeps its permissions when overwritten.
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	if perm := fi.Mode().Perm(); perm&0111 == 0 {
+		// Let execute whoever may read.
+		return os.Chmod(dst, perm|perm&0444>>2)
+	}
+	return nil
+}
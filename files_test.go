@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var verbatimMatchTests = []struct {
+	name string
+	want bool
+}{
+	{"testdata/golden.json", true},
+	{"internal/quotes/testdata/sub/out.txt", true},
+	{"internal/quotes/quotes.go", false},
+	{"web/assets/logo.svg", true},
+	{"web/assets/css/site.css", true},
+	{"docs/web/assets/logo.svg", false},
+	{"assets/logo.svg", false},
+	{"cmd/main.go", false},
+	{"build/schema.sql", true},
+	{"build/tool.go", false},
+}
+
+func TestVerbatimMatch(t *testing.T) {
+	list := verbatimList{"testdata", "web/assets", "build/*.sql"}
+	for _, tt := range verbatimMatchTests {
+		if got := list.match(tt.name); got != tt.want {
+			t.Errorf("match(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+var sameContentsTests = []struct {
+	name string
+	a, b string
+	want bool
+}{
+	{"empty", "", "", true},
+	{"equal", "abc", "abc", true},
+	{"different", "abc", "abd", false},
+	{"prefix", "abc", "abcd", false},
+	{"longer than a buffer", strings.Repeat("x", 100<<10), strings.Repeat("x", 100<<10), true},
+	{"differ in second buffer", strings.Repeat("x", 40<<10), strings.Repeat("x", 40<<10-1) + "y", false},
+	{"longer by a buffer", strings.Repeat("x", 32<<10), strings.Repeat("x", 64<<10), false},
+}
+
+func TestSameContents(t *testing.T) {
+	for _, tt := range sameContentsTests {
+		// Read a in small pieces, so that it fills buffers differently than b.
+		a := iotest.HalfReader(strings.NewReader(tt.a))
+		got, err := sameContents(a, strings.NewReader(tt.b))
+		if err != nil || got != tt.want {
+			t.Errorf("%s: sameContents = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := sameContents(iotest.ErrReader(io.ErrClosedPipe), strings.NewReader("x")); err != io.ErrClosedPipe {
+		t.Errorf("sameContents with a failing reader: error %v, want %v", err, io.ErrClosedPipe)
+	}
+}
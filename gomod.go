@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"go/version"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// sdkModule is the module path of the t-0 Network provider SDK,
+// whose version -sdk selects.
+const sdkModule = "github.com/t-0-network/provider-sdk-go"
+
+// A replaceList is the list of -replace flags, each old[@v]=new[@v].
+type replaceList []string
+
+func (l *replaceList) String() string { return strings.Join(*l, ",") }
+
+func (l *replaceList) Set(s string) error {
+	if _, err := parseReplace(s); err != nil {
+		return err
+	}
+	*l = append(*l, s)
+	return nil
+}
+
+// A replace is a replace directive, old[@v]=new[@v].
+type replace struct {
+	old, new module.Version
+}
+
+func (r replace) String() string {
+	return versioned(r.old) + "=" + versioned(r.new)
+}
+
+func versioned(m module.Version) string {
+	if m.Version == "" {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version
+}
+
+// parseReplace parses a replace directive in the form of -replace,
+// like go mod edit -replace does: the new path must have a version
+// unless it is a local directory, which must not.
+func parseReplace(s string) (replace, error) {
+	oldSpec, newSpec, ok := strings.Cut(s, "=")
+	if !ok {
+		return replace{}, fmt.Errorf("invalid replace %q: want old[@v]=new[@v]", s)
+	}
+	var r replace
+	var err error
+	if r.old, err = parseVersioned(strings.TrimSpace(oldSpec)); err != nil {
+		return replace{}, fmt.Errorf("invalid replace %q: %v", s, err)
+	}
+	if err := module.CheckImportPath(r.old.Path); err != nil {
+		return replace{}, fmt.Errorf("invalid replace %q: %v", s, err)
+	}
+	newSpec = strings.TrimSpace(newSpec)
+	if modfile.IsDirectoryPath(newSpec) {
+		r.new.Path = newSpec
+		return r, nil
+	}
+	if r.new, err = parseVersioned(newSpec); err != nil {
+		return replace{}, fmt.Errorf("invalid replace %q: %v", s, err)
+	}
+	if err := module.CheckImportPath(r.new.Path); err != nil {
+		return replace{}, fmt.Errorf("invalid replace %q: %v", s, err)
+	}
+	if r.new.Version == "" {
+		return replace{}, fmt.Errorf("invalid replace %q: %s is not a local directory and has no version", s, r.new.Path)
+	}
+	return r, nil
+}
+
+func parseVersioned(s string) (module.Version, error) {
+	path, vers, _ := strings.Cut(s, "@")
+	if vers != "" && !semver.IsValid(vers) {
+		return module.Version{}, fmt.Errorf("invalid version %q", vers)
+	}
+	return module.Version{Path: path, Version: vers}, nil
+}
+
+// goModVars returns the template variables set from the go.mod flags
+// -go, -toolchain, -sdk and -replace. The local directories of -replace
+// directives, given relative to the current directory, are recorded
+// relative to dir, the target directory, where the go.mod file is.
+// An -sdk version query other than a semantic version is resolved by
+// downloading the SDK.
+func goModVars(dir string) (map[string]string, error) {
+	vars := make(map[string]string)
+	if v := *goFlag; v != "" {
+		if !modfile.GoVersionRE.MatchString(v) {
+			return nil, fmt.Errorf("invalid -go: %q is not a Go version, such as 1.25", v)
+		}
+		vars["GoVersion"] = v
+	}
+	if v := *toolchainFlag; v != "" {
+		if v != "none" && !modfile.ToolchainRE.MatchString(v) {
+			return nil, fmt.Errorf("invalid -toolchain: %q is not a toolchain name, such as go1.25.1, or none", v)
+		}
+		vars["Toolchain"] = v
+	}
+	if v := *sdkFlag; v != "" {
+		if !semver.IsValid(v) || semver.Canonical(v) != v {
+			info, err := download(sdkModule + "@" + v)
+			if err != nil {
+				return nil, fmt.Errorf("resolving -sdk %s: %w", v, err)
+			}
+			v = info.Version
+		}
+		vars["SDKVersion"] = v
+	}
+	var lines []string
+	for _, s := range replaceFlags {
+		r, err := parseReplace(s)
+		if err != nil {
+			return nil, err
+		}
+		if modfile.IsDirectoryPath(r.new.Path) && !filepath.IsAbs(r.new.Path) {
+			if r.new.Path, err = relativeTo(dir, r.new.Path); err != nil {
+				return nil, err
+			}
+		}
+		lines = append(lines, r.String())
+	}
+	if len(lines) > 0 {
+		vars["Replace"] = strings.Join(lines, "\n")
+	}
+	return vars, nil
+}
+
+// relativeTo returns the local directory path, relative to the current
+// directory, as a path relative to dir, in the form used by go.mod files.
+func relativeTo(dir, path string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, abs)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if !strings.HasPrefix(rel, "../") && rel != ".." {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
+
+// fixGoMod rewrites the go.mod content in data for the new module dstMod:
+// it sets the module statement, rewrites the replace directives
+// referring to the template module srcMod, and applies the go.mod
+// settings in vars (see templateVars). It returns the rewritten
+// replace directives.
+//
+// A replaced module inside the template is now inside the new module.
+// A replacement module inside the template is part of the new module's
+// files, so it is replaced by its directory there instead.
+func fixGoMod(data []byte, srcMod, dstMod string, vars map[string]string) ([]byte, []replacement, error) {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing source module:\n%s", err)
+	}
+	f.AddModuleStmt(dstMod)
+
+	var reps []replacement
+	for _, r := range slices.Clone(f.Replace) {
+		old, new := r.Old, r.New
+		if rest, ok := inModule(old.Path, srcMod); ok {
+			old.Path = dstMod + rest
+		}
+		if rest, ok := inModule(new.Path, srcMod); ok && !modfile.IsDirectoryPath(new.Path) {
+			new = module.Version{Path: "./" + strings.TrimPrefix(rest, "/")}
+			if rest == "" {
+				new.Path = "."
+			}
+		}
+		if old == r.Old && new == r.New {
+			continue
+		}
+		reps = append(reps, replacement{
+			file: "go.mod",
+			line: r.Syntax.Start.Line,
+			old:  replace{r.Old, r.New}.String(),
+			new:  replace{old, new}.String(),
+		})
+		if err := f.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+			return nil, nil, err
+		}
+		if err := f.AddReplace(old.Path, old.Version, new.Path, new.Version); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if v := vars["GoVersion"]; v != "" {
+		if err := checkGoStmt(f, v); err != nil {
+			return nil, nil, err
+		}
+		if err := f.AddGoStmt(v); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch v := vars["Toolchain"]; v {
+	case "":
+	case "none":
+		f.DropToolchainStmt()
+	default:
+		if err := f.AddToolchainStmt(v); err != nil {
+			return nil, nil, err
+		}
+	}
+	if v := vars["SDKVersion"]; v != "" {
+		if err := f.AddRequire(sdkModule, v); err != nil {
+			return nil, nil, err
+		}
+	}
+	if list := vars["Replace"]; list != "" {
+		for _, s := range strings.Split(list, "\n") {
+			r, err := parseReplace(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := f.AddReplace(r.old.Path, r.old.Version, r.new.Path, r.new.Version); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	f.Cleanup()
+	new, err := f.Format()
+	if err != nil {
+		return nil, nil, fmt.Errorf("formatting go.mod: %v", err)
+	}
+	return new, reps, nil
+}
+
+// checkGoVersion reports an error if v, the -go version, is set and is
+// below the go line of the template's go.mod in fsys, which the template's
+// code and requirements need.
+func checkGoVersion(fsys fs.FS, v string) error {
+	if v == "" {
+		return nil
+	}
+	data, err := fs.ReadFile(fsys, "go.mod")
+	if err != nil {
+		return err
+	}
+	f, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return withKind(errParse, fmt.Errorf("parsing source module:\n%s", err))
+	}
+	return checkGoStmt(f, v)
+}
+
+// checkGoStmt reports an error if the go version v is below the go line of f.
+func checkGoStmt(f *modfile.File, v string) error {
+	if f.Go != nil && version.Compare("go"+v, "go"+f.Go.Version) < 0 {
+		return fmt.Errorf("invalid -go: %s is below go %s, which the template requires", v, f.Go.Version)
+	}
+	return nil
+}
+
+// inModule reports whether path is mod or a path inside it,
+// returning the rest of path after mod.
+func inModule(path, mod string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, mod)
+	if !ok || rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
@@ -0,0 +1,130 @@
+package main
+
+import "testing"
+
+// tmplGoMod is a template go.mod with replace directives inside the template.
+const tmplGoMod = `module example.com/tmpl
+
+go 1.25
+
+toolchain go1.25.1
+
+require github.com/t-0-network/provider-sdk-go v0.1.0
+
+replace example.com/tmpl/tools => ./tools
+
+replace example.com/other => example.com/tmpl/fork v1.0.0
+`
+
+var fixGoModTests = []struct {
+	name string
+	vars map[string]string
+	want string // "" for an error
+}{
+	{
+		name: "template replacements",
+		want: `module example.com/acme/payout
+
+go 1.25
+
+toolchain go1.25.1
+
+require github.com/t-0-network/provider-sdk-go v0.1.0
+
+replace example.com/acme/payout/tools => ./tools
+
+replace example.com/other => ./fork
+`,
+	},
+	{
+		name: "flags",
+		vars: map[string]string{
+			"GoVersion":  "1.25.3",
+			"Toolchain":  "none",
+			"SDKVersion": "v0.2.0",
+			"Replace":    "example.com/x=../x\nexample.com/y@v1.0.0=example.com/z@v1.1.0",
+		},
+		want: `module example.com/acme/payout
+
+go 1.25.3
+
+require github.com/t-0-network/provider-sdk-go v0.2.0
+
+replace example.com/acme/payout/tools => ./tools
+
+replace example.com/other => ./fork
+
+replace example.com/x => ../x
+
+replace example.com/y v1.0.0 => example.com/z v1.1.0
+`,
+	},
+	{
+		name: "toolchain",
+		vars: map[string]string{"Toolchain": "go1.26.0"},
+		want: `module example.com/acme/payout
+
+go 1.25
+
+toolchain go1.26.0
+
+require github.com/t-0-network/provider-sdk-go v0.1.0
+
+replace example.com/acme/payout/tools => ./tools
+
+replace example.com/other => ./fork
+`,
+	},
+	{
+		name: "go below template",
+		vars: map[string]string{"GoVersion": "1.24"},
+	},
+}
+
+func TestFixGoMod(t *testing.T) {
+	for _, tt := range fixGoModTests {
+		got, reps, err := fixGoMod([]byte(tmplGoMod), "example.com/tmpl", "example.com/acme/payout", tt.vars)
+		switch {
+		case tt.want == "":
+			if err == nil {
+				t.Errorf("%s: fixGoMod succeeded, want error", tt.name)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case string(got) != tt.want:
+			t.Errorf("%s: fixGoMod =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		case len(reps) != 2:
+			t.Errorf("%s: fixGoMod made %d replacements, want 2: %v", tt.name, len(reps), reps)
+		}
+	}
+}
+
+var parseReplaceTests = []struct {
+	in   string
+	want string // "" for an error
+}{
+	{"example.com/a=../a", "example.com/a=../a"},
+	{"example.com/a@v1.0.0 = ./a", "example.com/a@v1.0.0=./a"},
+	{"example.com/a=example.com/b@v1.2.0", "example.com/a=example.com/b@v1.2.0"},
+	{"example.com/a", ""},
+	{"example.com/a=example.com/b", ""},   // no version
+	{"example.com/a@latest=../a", ""},     // not a semantic version
+	{"example.com/a=example.com/b@x", ""}, // not a semantic version
+	{"example.com//a=../a", ""},           // not an import path
+}
+
+func TestParseReplace(t *testing.T) {
+	for _, tt := range parseReplaceTests {
+		r, err := parseReplace(tt.in)
+		switch {
+		case tt.want == "":
+			if err == nil {
+				t.Errorf("parseReplace(%q) = %v, want error", tt.in, r)
+			}
+		case err != nil:
+			t.Errorf("parseReplace(%q): %v", tt.in, err)
+		case r.String() != tt.want:
+			t.Errorf("parseReplace(%q) = %v, want %v", tt.in, r, tt.want)
+		}
+	}
+}
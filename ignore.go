@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ignoreFiles are the ignore files written into every new module,
+// with the entries each must contain: the files holding secrets,
+// which the Dockerfile would otherwise copy into the build context,
+// and build outputs.
+var ignoreFiles = []struct {
+	name    string
+	entries []string
+}{
+	{".gitignore", []string{
+		"# Secrets: never commit these.",
+		".env",
+		".env.*",
+		"!.env.example",
+		keystoreFile,
+		"",
+		"# Build outputs.",
+		"/provider",
+		"/bin/",
+		"*.exe",
+		"*.test",
+		"*.out",
+	}},
+	{".dockerignore", []string{
+		"# Secrets: never copy these into an image.",
+		".env",
+		".env.*",
+		"!.env.example",
+		keystoreFile,
+		"",
+		"# Build outputs and repository metadata.",
+		"provider",
+		"bin/",
+		"*.exe",
+		"*.test",
+		"*.out",
+		".git",
+	}},
+}
+
+// isIgnoreFile reports whether rel names one of the ignoreFiles.
+func isIgnoreFile(rel string) bool {
+	for _, f := range ignoreFiles {
+		if rel == f.name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeIgnore returns the contents of an ignore file holding the patterns
+// of old, followed by those from tmpl, the template's version of the file,
+// and entries that old lacks. Comments and blank lines are carried over
+// only in a new file, that is, when old is nil.
+// If old already has every pattern, mergeIgnore returns old unchanged.
+func mergeIgnore(old, tmpl []byte, entries []string) []byte {
+	var lines []string
+	if len(tmpl) > 0 {
+		lines = append(strings.Split(strings.TrimRight(string(tmpl), "\n"), "\n"), "")
+	}
+	lines = append(lines, entries...)
+
+	if old == nil {
+		return []byte(strings.Join(lines, "\n") + "\n")
+	}
+	have := map[string]bool{}
+	for _, line := range strings.Split(string(old), "\n") {
+		have[strings.TrimSpace(line)] = true
+	}
+	var add []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && !have[line] && !slices.Contains(add, line) {
+			add = append(add, line)
+		}
+	}
+	if len(add) == 0 {
+		return old
+	}
+	var buf bytes.Buffer
+	buf.Write(old)
+	if len(old) > 0 && !bytes.HasSuffix(old, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n# Added by provider-starter-go.\n")
+	for _, line := range add {
+		buf.WriteString(line + "\n")
+	}
+	return buf.Bytes()
+}
+
+// writeIgnores writes the ignoreFiles into dir, merging them with
+// any existing files. Tmpl holds the template's versions of the files,
+// if any, by name. It returns the action taken for each file, by name.
+// If dryRun is set, writeIgnores only reports the actions.
+func writeIgnores(dir string, tmpl map[string][]byte, dryRun bool) (map[string]string, error) {
+	actions := map[string]string{}
+	for _, f := range ignoreFiles {
+		dst := filepath.Join(dir, f.name)
+		old, err := os.ReadFile(dst)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		data := mergeIgnore(old, tmpl[f.name], f.entries)
+		switch {
+		case old == nil:
+			actions[f.name] = "generated"
+		case bytes.Equal(old, data):
+			actions[f.name] = "exists, unchanged"
+			continue
+		default:
+			actions[f.name] = "exists, missing entries added"
+		}
+		if !dryRun {
+			if err := os.WriteFile(dst, data, 0666); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return actions, nil
+}
+
+// checkSecretsTracked returns an error if dir is in a git repository
+// that already tracks one of the files holding secrets in dir,
+// which no ignore file can then keep out of later commits.
+func checkSecretsTracked(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	// Run git in the nearest existing directory.
+	top := abs
+	for {
+		if fi, err := os.Stat(top); err == nil && fi.IsDir() {
+			break
+		}
+		parent := filepath.Dir(top)
+		if parent == top {
+			return nil
+		}
+		top = parent
+	}
+	rel, err := filepath.Rel(top, abs)
+	if err != nil {
+		return err
+	}
+	for _, name := range gitExcluded {
+		out, err := runIn(top, "git", "ls-files", "--", filepath.Join(rel, name))
+		if err != nil {
+			// Not in a git repository, or no git.
+			return nil
+		}
+		if len(bytes.TrimSpace(out)) > 0 {
+			return fmt.Errorf("%s is tracked by git; remove it from the repository with git rm --cached before scaffolding into %s", filepath.Join(dir, name), dir)
+		}
+	}
+	return nil
+}
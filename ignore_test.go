@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+var mergeIgnoreTests = []struct {
+	name      string
+	old, tmpl string
+	oldNil    bool
+	want      string
+}{
+	{
+		name:   "new file",
+		oldNil: true,
+		tmpl:   "# Binaries\n/bin/\n",
+		want:   "# Binaries\n/bin/\n\n.env\nkeystore.json\n",
+	},
+	{
+		name:   "new file without template version",
+		oldNil: true,
+		want:   ".env\nkeystore.json\n",
+	},
+	{
+		name: "missing patterns added",
+		old:  "/bin/\n.env\n",
+		tmpl: "# Binaries\n/bin/\n/dist/\n",
+		want: "/bin/\n.env\n\n# Added by provider-starter-go.\n/dist/\nkeystore.json\n",
+	},
+	{
+		name: "no final newline",
+		old:  "/bin/",
+		want: "/bin/\n\n# Added by provider-starter-go.\n.env\nkeystore.json\n",
+	},
+	{
+		name: "unchanged",
+		old:  "  .env\nkeystore.json\n/dist/",
+		tmpl: "/dist/\n.env\n",
+		want: "  .env\nkeystore.json\n/dist/",
+	},
+}
+
+func TestMergeIgnore(t *testing.T) {
+	entries := []string{".env", "keystore.json"}
+	for _, tt := range mergeIgnoreTests {
+		var old []byte
+		if !tt.oldNil {
+			old = []byte(tt.old)
+		}
+		if got := mergeIgnore(old, []byte(tt.tmpl), entries); string(got) != tt.want {
+			t.Errorf("%s: mergeIgnore = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
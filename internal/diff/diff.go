@@ -0,0 +1,203 @@
+// Package diff implements line-based comparison of text
+// and formatting of the result as a unified diff.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// An OpKind is the kind of a line operation in an edit script.
+type OpKind int
+
+const (
+	Equal  OpKind = iota // line is present in both old and new
+	Delete               // line is present only in old
+	Insert               // line is present only in new
+)
+
+// An Op is a single line operation in an edit script.
+// Old and New are the 0-based line indexes in the old and new text;
+// for an Insert, Old is the index of the old line it precedes,
+// and for a Delete, New is the index of the new line it precedes.
+type Op struct {
+	Kind OpKind
+	Old  int
+	New  int
+}
+
+// Lines splits data into lines, keeping the trailing newline of each line.
+// A final line without a newline is returned as is.
+func Lines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// Ops returns an edit script turning the lines of old into the lines of new,
+// based on a longest common subsequence of the lines.
+func Ops(old, new []string) []Op {
+	// Trim common prefix and suffix, which is almost all of the text
+	// for typical edits, to keep the quadratic table below small.
+	pre := 0
+	for pre < len(old) && pre < len(new) && old[pre] == new[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(old)-pre && suf < len(new)-pre && old[len(old)-1-suf] == new[len(new)-1-suf] {
+		suf++
+	}
+	x := old[pre : len(old)-suf]
+	y := new[pre : len(new)-suf]
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]Op, 0, len(old)+len(new)-pre-suf)
+	for i := 0; i < pre; i++ {
+		ops = append(ops, Op{Equal, i, i})
+	}
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			ops = append(ops, Op{Equal, pre + i, pre + j})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, Op{Delete, pre + i, pre + j})
+			i++
+		default:
+			ops = append(ops, Op{Insert, pre + i, pre + j})
+			j++
+		}
+	}
+	for k := 0; k < suf; k++ {
+		ops = append(ops, Op{Equal, len(old) - suf + k, len(new) - suf + k})
+	}
+	return ops
+}
+
+// A Hunk is a group of changed lines along with their surrounding context.
+type Hunk struct {
+	OldLine  int // first line of the hunk in the old text, 1-based
+	OldCount int // number of old lines in the hunk
+	NewLine  int // first line of the hunk in the new text, 1-based
+	NewCount int // number of new lines in the hunk
+
+	// Lines holds the lines of the hunk, each prefixed by
+	// ' ' for context, '-' for deletion, or '+' for insertion.
+	// Every line ends in a newline, except possibly the last line
+	// of the old or new text.
+	Lines []string
+}
+
+// Hunks groups the edit script ops, which turns the lines x into the lines y,
+// into hunks with the given number of context lines around each change.
+// Changes separated by no more than 2*context unchanged lines share a hunk.
+func Hunks(x, y []string, ops []Op, context int) []Hunk {
+	var hunks []Hunk
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == Equal {
+			start++
+			continue
+		}
+		// Extend the hunk until the next run of more than
+		// 2*context equal lines, or the end of the script.
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].Kind != Equal {
+				end = k + 1
+				continue
+			}
+			if k-end >= 2*context {
+				break
+			}
+		}
+		lo := max(start-context, 0)
+		hi := min(end+context, len(ops))
+
+		h := Hunk{OldLine: ops[lo].Old + 1, NewLine: ops[lo].New + 1}
+		for _, op := range ops[lo:hi] {
+			switch op.Kind {
+			case Equal:
+				h.Lines = append(h.Lines, " "+x[op.Old])
+				h.OldCount++
+				h.NewCount++
+			case Delete:
+				h.Lines = append(h.Lines, "-"+x[op.Old])
+				h.OldCount++
+			case Insert:
+				h.Lines = append(h.Lines, "+"+y[op.New])
+				h.NewCount++
+			}
+		}
+		// An empty range is identified by the line before it.
+		if h.OldCount == 0 {
+			h.OldLine--
+		}
+		if h.NewCount == 0 {
+			h.NewLine--
+		}
+		hunks = append(hunks, h)
+		start = hi
+	}
+	return hunks
+}
+
+// Format formats hunks as a unified diff, labeling the texts with oldName and newName.
+func Format(oldName, newName string, hunks []Hunk) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks {
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", span(h.OldLine, h.OldCount), span(h.NewLine, h.NewCount))
+		for _, line := range h.Lines {
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+// Diff returns a unified diff of old and new, with 3 lines of context,
+// labeling the texts with oldName and newName.
+// If old and new are identical, Diff returns a nil slice.
+func Diff(oldName string, old []byte, newName string, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+	x := Lines(old)
+	y := Lines(new)
+	return Format(oldName, newName, Hunks(x, y, Ops(x, y), 3))
+}
+
+// span formats a hunk range in unified diff syntax.
+func span(line, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
@@ -0,0 +1,32 @@
+package diff
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+\ No newline at end of file
+`
+	got := string(Diff("old", []byte(old), "new", []byte(new)))
+	if got != want {
+		t.Errorf("Diff:\n%s\nwant:\n%s", got, want)
+	}
+
+	if d := Diff("old", []byte(old), "new", []byte(old)); d != nil {
+		t.Errorf("Diff of identical texts = %q, want nil", d)
+	}
+}
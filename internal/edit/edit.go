@@ -6,33 +6,59 @@
 package edit
 
 import (
+	"errors"
 	"fmt"
+	"go/token"
+	"io"
 	"sort"
+	"strings"
+
+	"github.com/t-0-network/provider-starter-go/internal/diff"
 )
 
 // A Buffer is a queue of edits to apply to a given byte slice.
 type Buffer struct {
-	old []byte
-	q   edits
+	old   []byte
+	q     edits
+	file  *token.File // file of the positions of the Pos methods, or nil
+	lines []int       // offsets of the starts of the lines of old, once computed
+}
+
+// An Edit records a single text modification: change the bytes in [Start,End) to New.
+type Edit struct {
+	Start int
+	End   int
+	New   string
 }
 
-// An edit records a single text modification: change the bytes in [start,end) to new.
-type edit struct {
-	start int
-	end   int
-	new   string
+func (e Edit) String() string {
+	return fmt.Sprintf("[%d,%d)->%q", e.Start, e.End, e.New)
 }
 
 // An edits is a list of edits that is sortable by start offset, breaking ties by end offset.
-type edits []edit
+type edits []Edit
 
 func (x edits) Len() int      { return len(x) }
 func (x edits) Swap(i, j int) { x[i], x[j] = x[j], x[i] }
 func (x edits) Less(i, j int) bool {
-	if x[i].start != x[j].start {
-		return x[i].start < x[j].start
+	if x[i].Start != x[j].Start {
+		return x[i].Start < x[j].Start
 	}
-	return x[i].end < x[j].end
+	return x[i].End < x[j].End
+}
+
+// ErrPosition is the error wrapped by the errors of the Try methods
+// when an edit position lies outside the original data.
+var ErrPosition = errors.New("invalid edit position")
+
+// An OverlapError reports two queued edits that change overlapping text.
+type OverlapError struct {
+	First  Edit
+	Second Edit
+}
+
+func (e *OverlapError) Error() string {
+	return fmt.Sprintf("overlapping edits: %v, %v", e.First, e.Second)
 }
 
 // NewBuffer returns a new buffer to accumulate changes to an initial data slice.
@@ -42,55 +68,395 @@ func NewBuffer(old []byte) *Buffer {
 	return &Buffer{old: old}
 }
 
+// NewFileBuffer is like NewBuffer, but the buffer also accepts positions
+// in file, which must describe old, as for the Pos methods.
+// It panics if the size of file is not the length of old.
+func NewFileBuffer(file *token.File, old []byte) *Buffer {
+	if file.Size() != len(old) {
+		panic(fmt.Sprintf("edit.NewFileBuffer: file %s has size %d, but data has %d bytes", file.Name(), file.Size(), len(old)))
+	}
+	return &Buffer{old: old, file: file}
+}
+
 // Insert inserts the new string at old[pos:pos].
+// It panics if pos is not a valid position in old.
 func (b *Buffer) Insert(pos int, new string) {
-	if pos < 0 || pos > len(b.old) {
-		panic("invalid edit position")
+	if err := b.TryInsert(pos, new); err != nil {
+		panic(err)
 	}
-	b.q = append(b.q, edit{pos, pos, new})
 }
 
 // Delete deletes the text old[start:end].
+// It panics if start and end are not valid positions in old.
 func (b *Buffer) Delete(start, end int) {
-	if end < start || start < 0 || end > len(b.old) {
-		panic("invalid edit position")
+	if err := b.TryDelete(start, end); err != nil {
+		panic(err)
 	}
-	b.q = append(b.q, edit{start, end, ""})
 }
 
 // Replace replaces old[start:end] with new.
+// It panics if start and end are not valid positions in old.
 func (b *Buffer) Replace(start, end int, new string) {
+	if err := b.TryReplace(start, end, new); err != nil {
+		panic(err)
+	}
+}
+
+// TryInsert is like Insert but returns an error instead of panicking.
+func (b *Buffer) TryInsert(pos int, new string) error {
+	return b.TryReplace(pos, pos, new)
+}
+
+// TryDelete is like Delete but returns an error instead of panicking.
+func (b *Buffer) TryDelete(start, end int) error {
+	return b.TryReplace(start, end, "")
+}
+
+// TryReplace is like Replace but returns an error instead of panicking.
+func (b *Buffer) TryReplace(start, end int, new string) error {
 	if end < start || start < 0 || end > len(b.old) {
-		panic("invalid edit position")
+		return fmt.Errorf("%w: [%d,%d) in %d bytes", ErrPosition, start, end, len(b.old))
 	}
-	b.q = append(b.q, edit{start, end, new})
+	b.q = append(b.q, Edit{start, end, new})
+	return nil
 }
 
-// Bytes returns a new byte slice containing the original data
-// with the queued edits applied.
-func (b *Buffer) Bytes() []byte {
-	// Sort edits by starting position and then by ending position.
-	// Breaking ties by ending position allows insertions at point x
-	// to be applied before a replacement of the text at [x, y).
+// Offset returns the offset in the original data of pos,
+// a position in the buffer's file.
+// It panics if the buffer has no file or pos is not in it.
+func (b *Buffer) Offset(pos token.Pos) int {
+	off, err := b.offset(pos)
+	if err != nil {
+		panic(err)
+	}
+	return off
+}
+
+func (b *Buffer) offset(pos token.Pos) (int, error) {
+	if b.file == nil {
+		return 0, fmt.Errorf("%w: buffer has no token.File for position %d", ErrPosition, pos)
+	}
+	if base := b.file.Base(); int(pos) < base || int(pos) > base+b.file.Size() {
+		return 0, fmt.Errorf("%w: position %d outside file %s", ErrPosition, pos, b.file.Name())
+	}
+	return b.file.Offset(pos), nil
+}
+
+// InsertPos is like Insert, with pos a position in the buffer's file.
+// It panics if the buffer has no file or pos is not in it.
+func (b *Buffer) InsertPos(pos token.Pos, new string) {
+	b.ReplacePos(pos, pos, new)
+}
+
+// DeletePos is like Delete, with start and end positions in the buffer's file.
+// It panics if the buffer has no file or start and end are not valid positions in it.
+func (b *Buffer) DeletePos(start, end token.Pos) {
+	b.ReplacePos(start, end, "")
+}
+
+// ReplacePos is like Replace, with start and end positions in the buffer's file.
+// It panics if the buffer has no file or start and end are not valid positions in it.
+func (b *Buffer) ReplacePos(start, end token.Pos, new string) {
+	if err := b.TryReplacePos(start, end, new); err != nil {
+		panic(err)
+	}
+}
+
+// TryInsertPos is like InsertPos but returns an error instead of panicking.
+func (b *Buffer) TryInsertPos(pos token.Pos, new string) error {
+	return b.TryReplacePos(pos, pos, new)
+}
+
+// TryDeletePos is like DeletePos but returns an error instead of panicking.
+func (b *Buffer) TryDeletePos(start, end token.Pos) error {
+	return b.TryReplacePos(start, end, "")
+}
+
+// TryReplacePos is like ReplacePos but returns an error instead of panicking.
+func (b *Buffer) TryReplacePos(start, end token.Pos, new string) error {
+	s, err := b.offset(start)
+	if err != nil {
+		return err
+	}
+	e, err := b.offset(end)
+	if err != nil {
+		return err
+	}
+	return b.TryReplace(s, e, new)
+}
+
+// lineStarts returns the offsets of the starts of the lines of the original
+// data. A final line without a newline counts as a line; empty data has none.
+func (b *Buffer) lineStarts() []int {
+	if b.lines == nil && len(b.old) > 0 {
+		b.lines = []int{0}
+		for i, c := range b.old {
+			if c == '\n' && i+1 < len(b.old) {
+				b.lines = append(b.lines, i+1)
+			}
+		}
+	}
+	return b.lines
+}
+
+// lineOffset returns the offset of the start of line, counting from 1,
+// with line one more than the number of lines standing for the end of the data.
+func (b *Buffer) lineOffset(line int) int {
+	starts := b.lineStarts()
+	if line > len(starts) {
+		return len(b.old)
+	}
+	return starts[line-1]
+}
+
+// OffsetOf returns the offset in the original data of the given line
+// and column, both counting from 1, with the column in bytes, as in a
+// token.Position. The column may be one past the end of the line's text,
+// addressing its newline or the end of the data. If the data ends in a
+// newline, the column of the last line may also be one past that newline,
+// addressing the end of the data, as go/token reports the end of a file.
+func (b *Buffer) OffsetOf(line, col int) (int, error) {
+	n := len(b.lineStarts())
+	if line < 1 || line > n {
+		return 0, fmt.Errorf("%w: line %d of %d", ErrPosition, line, n)
+	}
+	start, end := b.lineOffset(line), b.lineOffset(line+1)
+	if line < n && b.old[end-1] == '\n' {
+		end--
+	}
+	if col < 1 || start+col-1 > end {
+		return 0, fmt.Errorf("%w: line %d, column %d of %d", ErrPosition, line, col, end-start+1)
+	}
+	return start + col - 1, nil
+}
+
+// ReplaceLines replaces the lines first through last of the original data,
+// counting from 1 and including their final newline, with new, which
+// should therefore end in a newline itself unless it is empty.
+// It panics if first and last are not valid lines.
+func (b *Buffer) ReplaceLines(first, last int, new string) {
+	if err := b.TryReplaceLines(first, last, new); err != nil {
+		panic(err)
+	}
+}
+
+// TryReplaceLines is like ReplaceLines but returns an error instead of panicking.
+func (b *Buffer) TryReplaceLines(first, last int, new string) error {
+	if n := len(b.lineStarts()); first < 1 || last < first || last > n {
+		return fmt.Errorf("%w: lines %d-%d of %d", ErrPosition, first, last, n)
+	}
+	return b.TryReplace(b.lineOffset(first), b.lineOffset(last+1), new)
+}
+
+// InsertLineAfter inserts new as a line of its own after the given line
+// of the original data, counting from 1, or before the first line if line is 0.
+// A newline is added to new if it lacks one, and to the last line
+// if it lacks one.
+// It panics if line is not a valid line.
+func (b *Buffer) InsertLineAfter(line int, new string) {
+	if err := b.TryInsertLineAfter(line, new); err != nil {
+		panic(err)
+	}
+}
+
+// TryInsertLineAfter is like InsertLineAfter but returns an error instead of panicking.
+func (b *Buffer) TryInsertLineAfter(line int, new string) error {
+	n := len(b.lineStarts())
+	if line < 0 || line > n {
+		return fmt.Errorf("%w: line %d of %d", ErrPosition, line, n)
+	}
+	if !strings.HasSuffix(new, "\n") {
+		new += "\n"
+	}
+	pos := 0
+	if line > 0 {
+		pos = b.lineOffset(line + 1)
+	}
+	if pos == len(b.old) && pos > 0 && b.old[pos-1] != '\n' {
+		new = "\n" + new
+	}
+	return b.TryInsert(pos, new)
+}
+
+// sort sorts the queued edits by starting position and then by ending position,
+// and reports an *OverlapError if any two of them overlap.
+// Breaking ties by ending position allows insertions at point x
+// to be applied before a replacement of the text at [x, y).
+func (b *Buffer) sort() error {
 	sort.Stable(b.q)
+	for i := 1; i < len(b.q); i++ {
+		if b.q[i].Start < b.q[i-1].End {
+			return &OverlapError{b.q[i-1], b.q[i]}
+		}
+	}
+	return nil
+}
 
-	var new []byte
+// Apply returns a new byte slice containing the original data
+// with the queued edits applied.
+// If any queued edits overlap, Apply returns an *OverlapError.
+func (b *Buffer) Apply() ([]byte, error) {
+	if err := b.sort(); err != nil {
+		return nil, err
+	}
+
+	size := len(b.old)
+	for _, e := range b.q {
+		size += len(e.New) - (e.End - e.Start)
+	}
+	new := make([]byte, 0, size)
 	offset := 0
-	for i, e := range b.q {
-		if e.start < offset {
-			e0 := b.q[i-1]
-			panic(fmt.Sprintf("overlapping edits: [%d,%d)->%q, [%d,%d)->%q", e0.start, e0.end, e0.new, e.start, e.end, e.new))
-		}
-		new = append(new, b.old[offset:e.start]...)
-		offset = e.end
-		new = append(new, e.new...)
+	for _, e := range b.q {
+		new = append(new, b.old[offset:e.Start]...)
+		offset = e.End
+		new = append(new, e.New...)
 	}
 	new = append(new, b.old[offset:]...)
+	return new, nil
+}
+
+// WriteTo writes the original data with the queued edits applied to w,
+// without building the edited data in memory, and returns the number of
+// bytes written. It implements io.WriterTo.
+// If any queued edits overlap, WriteTo writes nothing and returns an *OverlapError.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	if err := b.sort(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	count := func(n int, err error) error {
+		total += int64(n)
+		return err
+	}
+	offset := 0
+	for _, e := range b.q {
+		if e.Start > offset {
+			if err := count(w.Write(b.old[offset:e.Start])); err != nil {
+				return total, err
+			}
+		}
+		offset = e.End
+		if e.New != "" {
+			if err := count(io.WriteString(w, e.New)); err != nil {
+				return total, err
+			}
+		}
+	}
+	if offset < len(b.old) {
+		if err := count(w.Write(b.old[offset:])); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Bytes returns a new byte slice containing the original data
+// with the queued edits applied.
+// It panics if any queued edits overlap.
+func (b *Buffer) Bytes() []byte {
+	new, err := b.Apply()
+	if err != nil {
+		panic(err)
+	}
 	return new
 }
 
 // String returns a string containing the original data
 // with the queued edits applied.
+// It panics if any queued edits overlap.
 func (b *Buffer) String() string {
 	return string(b.Bytes())
 }
+
+// A Hunk is a group of changed lines along with their surrounding context.
+type Hunk = diff.Hunk
+
+// A Patch describes the queued edits of a Buffer as the hunks of a unified diff.
+type Patch struct {
+	Hunks []Hunk
+}
+
+// Format formats the patch as a unified diff,
+// labeling the original and edited texts with oldName and newName.
+func (p *Patch) Format(oldName, newName string) []byte {
+	return diff.Format(oldName, newName, p.Hunks)
+}
+
+// Patch returns the queued edits as a patch against the original data,
+// with the given number of lines of context around each change.
+// Line numbers in the patch refer to the lines of the original data.
+// It panics if any queued edits overlap.
+func (b *Buffer) Patch(context int) *Patch {
+	if err := b.sort(); err != nil {
+		panic(err)
+	}
+
+	// starts[i] is the offset of the start of line i of the original data.
+	starts := b.lineStarts()
+	lineOf := func(pos int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > pos }) - 1
+	}
+	offsetOf := func(line int) int {
+		if line >= len(starts) {
+			return len(b.old)
+		}
+		return starts[line]
+	}
+
+	// Group the edits into runs touching disjoint ranges of whole lines,
+	// and diff the lines of each range before and after its edits.
+	var x, y []string
+	var ops []diff.Op
+	line := 0 // next original line to copy into x
+	for i := 0; i < len(b.q); {
+		lo := max(lineOf(b.q[i].Start), 0)
+		hi := lineOf(b.q[i].End) + 1
+		j := i
+		offset := offsetOf(lo)
+		var new []byte
+		for ; j < len(b.q) && (j == i || hi >= len(starts) || b.q[j].Start < offsetOf(hi)); j++ {
+			e := b.q[j]
+			hi = max(hi, lineOf(e.End)+1)
+			new = append(new, b.old[offset:e.Start]...)
+			new = append(new, e.New...)
+			offset = e.End
+		}
+		hi = min(hi, len(starts))
+		new = append(new, b.old[offset:offsetOf(hi)]...)
+
+		for ; line < lo; line++ {
+			ops = append(ops, diff.Op{Kind: diff.Equal, Old: len(x), New: len(y)})
+			x = append(x, string(b.old[starts[line]:offsetOf(line+1)]))
+			y = append(y, x[len(x)-1])
+		}
+		oldLines := diff.Lines(b.old[offsetOf(lo):offsetOf(hi)])
+		newLines := diff.Lines(new)
+		for _, op := range diff.Ops(oldLines, newLines) {
+			ops = append(ops, diff.Op{Kind: op.Kind, Old: len(x) + op.Old, New: len(y) + op.New})
+		}
+		x = append(x, oldLines...)
+		y = append(y, newLines...)
+		line = hi
+		i = j
+	}
+	for ; line < len(starts); line++ {
+		ops = append(ops, diff.Op{Kind: diff.Equal, Old: len(x), New: len(y)})
+		x = append(x, string(b.old[starts[line]:offsetOf(line+1)]))
+		y = append(y, x[len(x)-1])
+	}
+	return &Patch{Hunks: diff.Hunks(x, y, ops, context)}
+}
+
+// Diff returns the queued edits as a unified diff against the original data,
+// with the given number of lines of context around each change,
+// labeling the original and edited texts with oldName and newName.
+// Like diff.Diff, it returns a nil slice if the edits change nothing.
+// It panics if any queued edits overlap.
+func (b *Buffer) Diff(oldName, newName string, context int) []byte {
+	p := b.Patch(context)
+	if len(p.Hunks) == 0 {
+		return nil
+	}
+	return p.Format(oldName, newName)
+}
@@ -4,7 +4,15 @@
 
 package edit
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"testing"
+)
 
 func TestEdit(t *testing.T) {
 	b := NewBuffer([]byte("0123456789"))
@@ -26,3 +34,365 @@ func TestEdit(t *testing.T) {
 		t.Errorf("b.Bytes() = %q, want %q", sb, want)
 	}
 }
+
+func TestPatch(t *testing.T) {
+	old := "package p\n\nimport \"old/mod\"\n\nfunc f() {\n\tmod.F()\n}\n"
+	b := NewBuffer([]byte(old))
+	b.Replace(18, 27, `"new/mod"`)
+	b.Insert(len(old), "\nfunc g() {}\n")
+	b.Insert(0, "// Package p is new.\n")
+
+	want := `--- old
++++ new
+@@ -1,4 +1,5 @@
++// Package p is new.
+ package p
+ 
+-import "old/mod"
++import "new/mod"
+ 
+@@ -7 +8,3 @@
+ }
++
++func g() {}
+`
+	if got := string(b.Diff("old", "new", 1)); got != want {
+		t.Errorf("b.Diff(1):\n%s\nwant:\n%s", got, want)
+	}
+
+	unchanged := NewBuffer([]byte(old))
+	if d := unchanged.Diff("old", "new", 1); d != nil {
+		t.Errorf("Diff with no edits = %q, want nil", d)
+	}
+	unchanged.Replace(18, 27, `"old/mod"`)
+	if d := unchanged.Diff("old", "new", 1); d != nil {
+		t.Errorf("Diff with an edit changing nothing = %q, want nil", d)
+	}
+
+	p := b.Patch(0)
+	if len(p.Hunks) != 3 {
+		t.Fatalf("b.Patch(0) has %d hunks, want 3", len(p.Hunks))
+	}
+	h := p.Hunks[1]
+	if h.OldLine != 3 || h.OldCount != 1 || h.NewLine != 4 || h.NewCount != 1 {
+		t.Errorf("b.Patch(0).Hunks[1] = -%d,%d +%d,%d, want -3,1 +4,1", h.OldLine, h.OldCount, h.NewLine, h.NewCount)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	b := NewBuffer([]byte("0123456789"))
+	if err := b.TryReplace(5, 11, "x"); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryReplace(5, 11) = %v, want ErrPosition", err)
+	}
+	if err := b.TryDelete(4, 3); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryDelete(4, 3) = %v, want ErrPosition", err)
+	}
+	if err := b.TryInsert(-1, "x"); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryInsert(-1) = %v, want ErrPosition", err)
+	}
+
+	if err := b.TryReplace(2, 6, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.TryDelete(5, 8); err != nil {
+		t.Fatal(err)
+	}
+	_, err := b.Apply()
+	var overlap *OverlapError
+	if !errors.As(err, &overlap) {
+		t.Fatalf("b.Apply() = %v, want *OverlapError", err)
+	}
+	if want := (Edit{2, 6, "x"}); overlap.First != want {
+		t.Errorf("overlap.First = %v, want %v", overlap.First, want)
+	}
+	if want := (Edit{5, 8, ""}); overlap.Second != want {
+		t.Errorf("overlap.Second = %v, want %v", overlap.Second, want)
+	}
+
+	defer func() {
+		e, _ := recover().(error)
+		if !errors.As(e, &overlap) {
+			t.Errorf("b.Bytes() panicked with %v, want *OverlapError", e)
+		}
+	}()
+	b.Bytes()
+	t.Errorf("b.Bytes() did not panic")
+}
+
+func TestLines(t *testing.T) {
+	old := "A=1\nB=2\nC=3"
+	b := NewBuffer([]byte(old))
+	for _, tt := range []struct {
+		line, col, want int
+	}{
+		{1, 1, 0},
+		{1, 4, 3},
+		{2, 3, 6},
+		{3, 4, 11},
+	} {
+		if got, err := b.OffsetOf(tt.line, tt.col); err != nil || got != tt.want {
+			t.Errorf("b.OffsetOf(%d, %d) = %d, %v, want %d", tt.line, tt.col, got, err, tt.want)
+		}
+	}
+	for _, lc := range [][2]int{{0, 1}, {4, 1}, {1, 0}, {1, 5}, {3, 5}} {
+		if _, err := b.OffsetOf(lc[0], lc[1]); !errors.Is(err, ErrPosition) {
+			t.Errorf("b.OffsetOf(%d, %d) = %v, want ErrPosition", lc[0], lc[1], err)
+		}
+	}
+
+	// go/token reports the end of a file ending in a newline
+	// one column past that newline.
+	src := "package p\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eof := fset.Position(f.FileEnd)
+	nb := NewBuffer([]byte(src))
+	if got, err := nb.OffsetOf(eof.Line, eof.Column); err != nil || got != len(src) {
+		t.Errorf("b.OffsetOf(%d, %d) at the end of %q = %d, %v, want %d", eof.Line, eof.Column, src, got, err, len(src))
+	}
+	if _, err := nb.OffsetOf(1, 12); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.OffsetOf(1, 12) in %q = %v, want ErrPosition", src, err)
+	}
+
+	b.ReplaceLines(2, 2, "B=two\n")
+	b.InsertLineAfter(0, "# settings")
+	b.InsertLineAfter(1, "A2=1\n")
+	b.InsertLineAfter(3, "D=4")
+	want := "# settings\nA=1\nA2=1\nB=two\nC=3\nD=4\n"
+	if got := b.String(); got != want {
+		t.Errorf("b.String() = %q, want %q", got, want)
+	}
+
+	if err := b.TryReplaceLines(2, 4, ""); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryReplaceLines(2, 4) = %v, want ErrPosition", err)
+	}
+	if err := b.TryInsertLineAfter(4, "x"); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryInsertLineAfter(4) = %v, want ErrPosition", err)
+	}
+}
+
+func TestPos(t *testing.T) {
+	src := "package p\n\nvar x = 1\n"
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewFileBuffer(fset.File(f.Pos()), []byte(src))
+	spec := f.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	b.ReplacePos(spec.Names[0].Pos(), spec.Names[0].End(), "y")
+	b.InsertPos(spec.Values[0].Pos(), "-")
+	b.ReplacePos(f.Name.Pos(), f.Name.End(), "q")
+	if got, want := b.String(), "package q\n\nvar y = -1\n"; got != want {
+		t.Errorf("b.String() = %q, want %q", got, want)
+	}
+	if got := b.Offset(spec.Values[0].Pos()); got != 19 {
+		t.Errorf("b.Offset(value) = %d, want 19", got)
+	}
+
+	if err := b.TryDeletePos(f.Pos(), token.Pos(fset.Base()+1)); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.TryDeletePos past the end of the file = %v, want ErrPosition", err)
+	}
+	if err := NewBuffer([]byte(src)).TryInsertPos(f.Pos(), "x"); !errors.Is(err, ErrPosition) {
+		t.Errorf("TryInsertPos on a buffer without a file = %v, want ErrPosition", err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	b := NewBuffer([]byte("import \"old/mod\"\n\nvar s = \"old/mod\"\n"))
+	b.Replace(7, 16, `"new/mod"`)
+
+	var imports, literals EditSet
+	imports.Replace(7, 16, `"new/mod"`) // also queued in b
+	imports.Insert(7, "mod ")
+	literals.Replace(26, 35, `"new/mod"`)
+	literals.Insert(0, "// Rewritten.\n")
+	if err := imports.Merge(&literals); err != nil {
+		t.Fatal(err)
+	}
+	if err := imports.Merge(&literals); err != nil {
+		t.Fatalf("merging a set twice: %v", err)
+	}
+	if n := imports.Len(); n != 4 {
+		t.Errorf("imports.Len() = %d after merging, want 4", n)
+	}
+	if err := b.Merge(&imports); err != nil {
+		t.Fatal(err)
+	}
+	want := "// Rewritten.\nimport mod \"new/mod\"\n\nvar s = \"new/mod\"\n"
+	if got := b.String(); got != want {
+		t.Errorf("b.String() = %q, want %q", got, want)
+	}
+
+	for _, e := range []Edit{
+		{8, 10, "x"},   // inside a replacement
+		{20, 30, "x"},  // overlapping the end of a replacement
+		{7, 16, `"x"`}, // the same text replaced differently
+	} {
+		var s EditSet
+		s.Replace(e.Start, e.End, e.New)
+		var conflict *ConflictError
+		if err := b.Merge(&s); !errors.As(err, &conflict) || conflict.New != e {
+			t.Errorf("b.Merge(%v) = %v, want *ConflictError for it", e, err)
+		}
+	}
+	if got := b.String(); got != want {
+		t.Errorf("b.String() after failed merges = %q, want %q", got, want)
+	}
+
+	// A different insertion at the same position follows the queued one,
+	// as it would if inserted into b directly.
+	var pkg EditSet
+	pkg.Insert(7, "pkg ")
+	if err := b.Merge(&pkg); err != nil {
+		t.Fatal(err)
+	}
+	want = "// Rewritten.\nimport mod pkg \"new/mod\"\n\nvar s = \"new/mod\"\n"
+	if got := b.String(); got != want {
+		t.Errorf("b.String() after merging an insertion at the same position = %q, want %q", got, want)
+	}
+
+	var s EditSet
+	s.Insert(100, "x")
+	if err := b.Merge(&s); !errors.Is(err, ErrPosition) {
+		t.Errorf("b.Merge of an edit past the end = %v, want ErrPosition", err)
+	}
+
+	// Edits within the merged set are deduped and checked against each other.
+	b = NewBuffer([]byte("0123456789"))
+	var dups EditSet
+	dups.Insert(3, "x")
+	dups.Insert(3, "x")
+	if err := b.Merge(&dups); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "012x3456789"; got != want {
+		t.Errorf("b.String() after merging a duplicate insertion = %q, want %q", got, want)
+	}
+	var overlap EditSet
+	overlap.Replace(5, 8, "a")
+	overlap.Replace(6, 9, "b")
+	var oerr *OverlapError
+	if err := b.Merge(&overlap); !errors.As(err, &oerr) {
+		t.Errorf("b.Merge of overlapping edits = %v, want *OverlapError", err)
+	}
+	var set EditSet
+	if err := set.Merge(&overlap); !errors.As(err, &oerr) {
+		t.Errorf("set.Merge of overlapping edits = %v, want *OverlapError", err)
+	}
+	if got, want := b.String(), "012x3456789"; got != want {
+		t.Errorf("b.String() after failed merge = %q, want %q", got, want)
+	}
+}
+
+func TestRebase(t *testing.T) {
+	old := "a := f(x)\nb := g(y)\n"
+	var first, second EditSet
+	first.Replace(5, 6, "ff")   // f -> ff
+	first.Insert(10, "// b\n")  // before line 2
+	first.Replace(15, 16, "gg") // g -> gg
+	second.Replace(0, 1, "A")   // a -> A
+	second.Replace(5, 6, "ff")  // the same as in first
+	second.Insert(9, ",z")      // at the end of line 1, before the inserted comment
+	second.Replace(17, 18, "Y") // y -> Y
+
+	b := NewBuffer([]byte(old))
+	if err := b.Merge(&first); err != nil {
+		t.Fatal(err)
+	}
+	mid := b.Bytes()
+	rebased, err := second.Rebase(&first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := rebased.Len(); n != 3 {
+		t.Errorf("rebased.Len() = %d, want 3", n)
+	}
+	b = NewBuffer(mid)
+	if err := b.Merge(rebased); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "A := ff(x),z\n// b\nb := gg(Y)\n"; got != want {
+		t.Errorf("after rebase: %q, want %q", got, want)
+	}
+
+	// An insertion at the position of an applied one follows it.
+	var same EditSet
+	same.Insert(10, "// c\n")
+	if rebased, err = same.Rebase(&first); err != nil {
+		t.Fatal(err)
+	}
+	b = NewBuffer(mid)
+	if err := b.Merge(rebased); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "a := ff(x)\n// b\n// c\nb := gg(y)\n"; got != want {
+		t.Errorf("after rebasing an insertion at the same position: %q, want %q", got, want)
+	}
+
+	var conflicting EditSet
+	conflicting.Replace(4, 7, " h")
+	var conflict *ConflictError
+	if _, err := conflicting.Rebase(&first); !errors.As(err, &conflict) {
+		t.Errorf("Rebase of a conflicting set = %v, want *ConflictError", err)
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	b := NewBuffer([]byte("0123456789"))
+	b.Insert(0, "<")
+	b.Replace(2, 5, "two-four")
+	b.Delete(7, 9)
+	b.Insert(10, ">")
+	want := b.String()
+
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want || n != int64(len(want)) {
+		t.Errorf("b.WriteTo() wrote %q, returning %d, want %q and %d", got, n, want, len(want))
+	}
+
+	b.Replace(3, 4, "x")
+	buf.Reset()
+	var overlap *OverlapError
+	if _, err := b.WriteTo(&buf); !errors.As(err, &overlap) || buf.Len() > 0 {
+		t.Errorf("b.WriteTo() with overlapping edits = %v, wrote %q; want *OverlapError and nothing written", err, buf.Bytes())
+	}
+}
+
+// largeBuffer returns a buffer for about 1 MB of generated Go code,
+// with edits rewriting an import path on every line.
+func largeBuffer() *Buffer {
+	line := []byte("\t_ = \"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment\" // generated\n")
+	data := bytes.Repeat(line, 1<<20/len(line))
+	buf := NewBuffer(data)
+	for off := 0; off < len(data); off += len(line) {
+		start := off + bytes.Index(line, []byte("github.com"))
+		buf.Replace(start, start+len("github.com/t-0-network/provider-sdk-go"), "example.com/fork/sdk")
+	}
+	return buf
+}
+
+func BenchmarkBytes(b *testing.B) {
+	buf := largeBuffer()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		buf.Bytes()
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	buf := largeBuffer()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		buf.WriteTo(io.Discard)
+	}
+}
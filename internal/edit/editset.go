@@ -0,0 +1,213 @@
+package edit
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// An EditSet is a set of edits to some data, built independently of
+// a Buffer and merged into one later, so that separate rewrite passes
+// can each contribute edits to the same file.
+// The zero value is an empty set ready to use.
+type EditSet struct {
+	q edits
+}
+
+// A ConflictError reports an edit that conflicts with one already in the
+// Buffer or EditSet it is merged into: the two change overlapping text,
+// so that there is no single way to apply both.
+type ConflictError struct {
+	Existing Edit
+	New      Edit
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting edits: %v and %v", e.Existing, e.New)
+}
+
+// Insert adds an edit inserting new at pos.
+// It panics if pos is negative.
+func (s *EditSet) Insert(pos int, new string) {
+	s.Replace(pos, pos, new)
+}
+
+// Delete adds an edit deleting the text in [start,end).
+// It panics if start is negative or end is before start.
+func (s *EditSet) Delete(start, end int) {
+	s.Replace(start, end, "")
+}
+
+// Replace adds an edit replacing the text in [start,end) with new.
+// It panics if start is negative or end is before start.
+// The positions are checked against the data when the set is merged
+// into a Buffer.
+func (s *EditSet) Replace(start, end int, new string) {
+	if end < start || start < 0 {
+		panic(fmt.Errorf("%w: [%d,%d)", ErrPosition, start, end))
+	}
+	s.q = append(s.q, Edit{start, end, new})
+}
+
+// Len returns the number of edits in s.
+func (s *EditSet) Len() int {
+	return len(s.q)
+}
+
+// Edits returns the edits in s, sorted by starting position
+// and then by ending position.
+func (s *EditSet) Edits() []Edit {
+	q := slices.Clone(s.q)
+	sort.Stable(q)
+	return q
+}
+
+// Merge adds the edits in t to s. An edit identical to one already in s,
+// or to another one in t, is added only once. Different insertions at the
+// same position are all kept, those in s first, as Buffer.Insert keeps
+// them in the order they are queued. If an edit in t conflicts
+// with one in s, Merge returns a *ConflictError, and if edits in s or
+// in t overlap each other, an *OverlapError; in both cases it leaves
+// s unchanged.
+func (s *EditSet) Merge(t *EditSet) error {
+	q, err := merge(s.q, t.q)
+	if err != nil {
+		return err
+	}
+	s.q = q
+	return nil
+}
+
+// Merge queues the edits in s, which must refer to positions in the
+// buffer's original data, like s.Merge would add them to a set holding
+// the queued edits, so that text they insert at the position of a queued
+// insertion follows it. If an edit lies outside the original data, Merge
+// returns an error wrapping ErrPosition; if it conflicts with a queued
+// edit, a *ConflictError; and if queued edits or edits in s overlap each
+// other, an *OverlapError.
+// In all those cases Merge leaves the queue unchanged.
+func (b *Buffer) Merge(s *EditSet) error {
+	for _, e := range s.q {
+		if e.End > len(b.old) {
+			return fmt.Errorf("%w: [%d,%d) in %d bytes", ErrPosition, e.Start, e.End, len(b.old))
+		}
+	}
+	q, err := merge(b.q, s.q)
+	if err != nil {
+		return err
+	}
+	b.q = q
+	return nil
+}
+
+// merge returns the edits in dst followed by those in src that are not
+// already in dst. It returns an *OverlapError if edits in dst or src
+// overlap each other, and a *ConflictError if an edit in src conflicts
+// with one in dst. Identical edits in src are kept only once.
+func merge(dst, src edits) (edits, error) {
+	sorted, err := sortEdits(dst)
+	if err != nil {
+		return nil, err
+	}
+	if src, err = sortEdits(dedupe(src)); err != nil {
+		return nil, err
+	}
+	q := slices.Clip(dst)
+Src:
+	for _, e := range src {
+		// As the edits in sorted do not overlap, their ends are sorted too,
+		// so the ones that may conflict with e form a run starting at i.
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i].End >= e.Start })
+		for ; i < len(sorted) && sorted[i].Start <= e.End; i++ {
+			switch a := sorted[i]; {
+			case a == e:
+				continue Src
+			case e.Start < a.End && a.Start < e.End:
+				return nil, &ConflictError{a, e}
+			}
+		}
+		q = append(q, e)
+	}
+	return q, nil
+}
+
+// sortEdits returns a copy of q sorted as Buffer.sort sorts its queue,
+// or an *OverlapError if any two of the edits overlap.
+func sortEdits(q edits) (edits, error) {
+	sorted := slices.Clone(q)
+	sort.Stable(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Start < sorted[i-1].End {
+			return nil, &OverlapError{sorted[i-1], sorted[i]}
+		}
+	}
+	return sorted, nil
+}
+
+// dedupe returns the edits in q, keeping only the first of identical ones.
+func dedupe(q edits) edits {
+	seen := make(map[Edit]bool)
+	var out edits
+	for _, e := range q {
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Rebase returns the edits in s, which refer to positions in some data,
+// translated to refer to positions in the result of applying the edits
+// in applied to that data, so that passes making edits can run one after
+// the other. An edit in s that is identical to one in applied is dropped,
+// as its change has already been made, and identical edits in s are kept
+// only once. Text inserted by applied at the
+// start of an edit in s stays before it, and text inserted at its end
+// stays after it. If an edit in s conflicts with one in applied, Rebase
+// returns a *ConflictError; if the edits in applied or in s overlap each
+// other, an *OverlapError.
+func (s *EditSet) Rebase(applied *EditSet) (*EditSet, error) {
+	a, err := sortEdits(applied.q)
+	if err != nil {
+		return nil, err
+	}
+	// shift[i] is the change in length made by the edits a[:i].
+	shift := make([]int, len(a)+1)
+	for i, e := range a {
+		shift[i+1] = shift[i] + len(e.New) - (e.End - e.Start)
+	}
+	// after returns pos translated past the text inserted at pos.
+	after := func(pos int) int {
+		i := sort.Search(len(a), func(i int) bool { return a[i].End > pos })
+		return pos + shift[i]
+	}
+	// before returns pos translated ahead of the text inserted at pos.
+	before := func(pos int) int {
+		i := sort.Search(len(a), func(i int) bool {
+			return a[i].End > pos || a[i].End == pos && a[i].Start == pos
+		})
+		return pos + shift[i]
+	}
+
+	if _, err := merge(a, s.q); err != nil {
+		return nil, err
+	}
+	done := make(map[Edit]bool)
+	for _, e := range a {
+		done[e] = true
+	}
+	t := new(EditSet)
+	for _, e := range dedupe(s.q) {
+		if done[e] {
+			continue
+		}
+		if e.Start == e.End {
+			pos := after(e.Start)
+			t.q = append(t.q, Edit{pos, pos, e.New})
+			continue
+		}
+		t.q = append(t.q, Edit{after(e.Start), before(e.End), e.New})
+	}
+	return t, nil
+}
@@ -0,0 +1,127 @@
+// Package merge implements line-based three-way merging of text.
+package merge
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/t-0-network/provider-starter-go/internal/diff"
+)
+
+// Labels name the three versions of the text in conflict markers.
+type Labels struct {
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+// Merge merges the changes from base to ours and from base to theirs,
+// and returns the merged text along with the number of conflicts.
+// Changes to separate parts of base are combined,
+// as are identical changes to the same part of base.
+// Differing changes to the same part of base are conflicts,
+// written as both versions surrounded by diff3-style conflict markers:
+//
+//	<<<<<<< ours
+//	...
+//	||||||| base
+//	...
+//	=======
+//	...
+//	>>>>>>> theirs
+func Merge(base, ours, theirs []byte, labels Labels) ([]byte, int) {
+	o := diff.Lines(base)
+	a := diff.Lines(ours)
+	b := diff.Lines(theirs)
+	matchA := match(o, a)
+	matchB := match(o, b)
+
+	var out bytes.Buffer
+	conflicts := 0
+	i, ia, ib := 0, 0, 0
+	for {
+		// Copy the lines that are unchanged in both versions.
+		n := 0
+		for i+n < len(o) && matchA[i+n] == ia+n && matchB[i+n] == ib+n {
+			n++
+		}
+		if n > 0 {
+			write(&out, o[i:i+n])
+			i, ia, ib = i+n, ia+n, ib+n
+			continue
+		}
+		if i == len(o) && ia == len(a) && ib == len(b) {
+			break
+		}
+
+		// Find the end of the changed chunk: the next base line
+		// present in both versions, or the end of the text.
+		j := i
+		for j < len(o) && (matchA[j] < 0 || matchB[j] < 0) {
+			j++
+		}
+		ja, jb := len(a), len(b)
+		if j < len(o) {
+			ja, jb = matchA[j], matchB[j]
+		}
+
+		chunkO, chunkA, chunkB := o[i:j], a[ia:ja], b[ib:jb]
+		switch {
+		case slices.Equal(chunkA, chunkO):
+			write(&out, chunkB)
+		case slices.Equal(chunkB, chunkO), slices.Equal(chunkA, chunkB):
+			write(&out, chunkA)
+		default:
+			conflicts++
+			marker(&out, "<<<<<<<", labels.Ours)
+			write(&out, chunkA)
+			newline(&out)
+			marker(&out, "|||||||", labels.Base)
+			write(&out, chunkO)
+			newline(&out)
+			marker(&out, "=======", "")
+			write(&out, chunkB)
+			newline(&out)
+			marker(&out, ">>>>>>>", labels.Theirs)
+		}
+		i, ia, ib = j, ja, jb
+	}
+	return out.Bytes(), conflicts
+}
+
+// match returns, for each line of x, the index of the matching line in y,
+// or -1 if the line was deleted.
+func match(x, y []string) []int {
+	m := make([]int, len(x))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, op := range diff.Ops(x, y) {
+		if op.Kind == diff.Equal {
+			m[op.Old] = op.New
+		}
+	}
+	return m
+}
+
+func write(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// newline terminates the last line of out, if needed, so that
+// a conflict marker can follow.
+func newline(out *bytes.Buffer) {
+	if b := out.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		out.WriteByte('\n')
+	}
+}
+
+func marker(out *bytes.Buffer, marker, label string) {
+	out.WriteString(marker)
+	if label != "" {
+		out.WriteString(" " + label)
+	}
+	out.WriteByte('\n')
+}
@@ -0,0 +1,59 @@
+package merge
+
+import "testing"
+
+var labels = Labels{Base: "base", Ours: "ours", Theirs: "theirs"}
+
+var mergeTests = []struct {
+	name               string
+	base, ours, theirs string
+	want               string
+	conflicts          int
+}{
+	{
+		name:   "disjoint",
+		base:   "a\nb\nc\nd\ne\n",
+		ours:   "a\nB\nc\nd\ne\n",
+		theirs: "a\nb\nc\nd\nE\nf\n",
+		want:   "a\nB\nc\nd\nE\nf\n",
+	},
+	{
+		name:   "same change",
+		base:   "a\nb\nc\n",
+		ours:   "a\nx\nc\n",
+		theirs: "a\nx\nc\n",
+		want:   "a\nx\nc\n",
+	},
+	{
+		name:   "insert at start and delete at end",
+		base:   "a\nb\nc\n",
+		ours:   "0\na\nb\nc\n",
+		theirs: "a\nb\n",
+		want:   "0\na\nb\n",
+	},
+	{
+		name:      "conflict",
+		base:      "a\nb\nc\n",
+		ours:      "a\nours\nc\n",
+		theirs:    "a\ntheirs\nc\n",
+		want:      "a\n<<<<<<< ours\nours\n||||||| base\nb\n=======\ntheirs\n>>>>>>> theirs\nc\n",
+		conflicts: 1,
+	},
+	{
+		name:      "conflict without final newline",
+		base:      "a\nb",
+		ours:      "a\nc",
+		theirs:    "a\nd",
+		want:      "a\n<<<<<<< ours\nc\n||||||| base\nb\n=======\nd\n>>>>>>> theirs\n",
+		conflicts: 1,
+	},
+}
+
+func TestMerge(t *testing.T) {
+	for _, tt := range mergeTests {
+		got, conflicts := Merge([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), labels)
+		if string(got) != tt.want || conflicts != tt.conflicts {
+			t.Errorf("%s: Merge = %q, %d conflicts, want %q, %d conflicts", tt.name, got, conflicts, tt.want, tt.conflicts)
+		}
+	}
+}
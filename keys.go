@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"golang.org/x/term"
+)
+
+// Variables in .env, and the environment, configuring the provider's key.
+const (
+	privateKeyVar         = "PROVIDER_PRIVATE_KEY"
+	keystorePathVar       = "PROVIDER_KEYSTORE_PATH"
+	keystorePassphraseVar = "PROVIDER_KEYSTORE_PASSPHRASE"
+)
+
+// keystoreFile is the name of the encrypted keystore written by -keystore,
+// relative to the new module root.
+const keystoreFile = "keystore.json"
+
+// privateKeyHex returns the 0x-prefixed hex encoding of key.
+func privateKeyHex(key *ecdsa.PrivateKey) string {
+	return "0x" + hex.EncodeToString(crypto.FromECDSA(key))
+}
+
+// publicKeyHex returns the 0x-prefixed hex encoding of the uncompressed public key.
+func publicKeyHex(pub *ecdsa.PublicKey) string {
+	return "0x" + hex.EncodeToString(crypto.FromECDSAPub(pub))
+}
+
+// parsePrivateKey parses a hex-encoded secp256k1 private key,
+// with or without a 0x prefix.
+func parsePrivateKey(s string) (*ecdsa.PrivateKey, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return key, nil
+}
+
+// loadPrivateKey loads the private key from source: the name of a file
+// holding the hex-encoded key, or a .env file setting PROVIDER_PRIVATE_KEY,
+// or "-" to read either from standard input. The key itself is refused,
+// as command-line arguments show in ps output and shell history.
+func loadPrivateKey(source string) (*ecdsa.PrivateKey, error) {
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		if _, perr := parsePrivateKey(source); perr == nil && errors.Is(err, fs.ErrNotExist) {
+			// Do not echo the key in the error.
+			return nil, errors.New("the private key cannot be given as an argument; write it to a file, or pass - and write it to standard input")
+		}
+		return nil, err
+	}
+	if env, err := godotenv.UnmarshalBytes(data); err == nil && env[privateKeyVar] != "" {
+		data = []byte(env[privateKeyVar])
+	}
+	key, err := parsePrivateKey(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	return key, nil
+}
+
+// cmdKeygen implements the keygen command, which generates a new
+// provider key pair and writes it in .env format.
+func cmdKeygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := flags.String("o", "", "write the key pair to `file` instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest keygen [-o file]\n")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		log.Fatal(err)
+	}
+	data := fmt.Sprintf("%s=%s\nPROVIDER_PUBLIC_KEY=%s\n# address %s\n",
+		privateKeyVar, privateKeyHex(key), publicKeyHex(&key.PublicKey), crypto.PubkeyToAddress(key.PublicKey))
+	if *out == "" {
+		fmt.Print(data)
+		return
+	}
+	// The file holds a secret: keep it private, and never replace an existing key.
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote key pair for address %s to %s", crypto.PubkeyToAddress(key.PublicKey), *out)
+}
+
+// cmdPubkey implements the pubkey command, which prints the public key
+// and address of an existing provider private key.
+func cmdPubkey(args []string) {
+	flags := flag.NewFlagSet("pubkey", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest pubkey [file | -]\n\n")
+		fmt.Fprintf(os.Stderr, "With no argument, pubkey uses $%s, or else the key in .env.\n", privateKeyVar)
+		os.Exit(2)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+	}
+
+	var key *ecdsa.PrivateKey
+	var err error
+	switch env := os.Getenv(privateKeyVar); {
+	case flags.NArg() == 1:
+		key, err = loadPrivateKey(flags.Arg(0))
+	case env != "":
+		key, err = parsePrivateKey(env)
+	default:
+		key, err = loadPrivateKey(".env")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("public key: %s\naddress:    %s\n", publicKeyHex(&key.PublicKey), crypto.PubkeyToAddress(key.PublicKey))
+}
+
+// encryptKey returns key encrypted with passphrase, as a go-ethereum
+// JSON keystore (Web3 Secret Storage) file.
+func encryptKey(key *ecdsa.PrivateKey, passphrase string) ([]byte, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	return keystore.EncryptKey(&keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+}
+
+// readPassphrase returns the passphrase for a new keystore:
+// $PROVIDER_KEYSTORE_PASSPHRASE if set, or else a passphrase
+// entered twice on the terminal.
+func readPassphrase() (string, error) {
+	if p := os.Getenv(keystorePassphraseVar); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no keystore passphrase: set $%s or run in a terminal", keystorePassphraseVar)
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "Keystore passphrase: ")
+		p1, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if len(p1) == 0 {
+			fmt.Fprintf(os.Stderr, "  the passphrase must not be empty\n")
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Repeat passphrase: ")
+		p2, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		if string(p1) != string(p2) {
+			fmt.Fprintf(os.Stderr, "  the passphrases do not match\n")
+			continue
+		}
+		return string(p1), nil
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var loadPrivateKeyTests = []struct {
+	name  string
+	file  string // contents of the key file; "-" for none
+	stdin bool   // pass the file as standard input rather than by name
+	err   string // text in the error, "" if the key loads
+}{
+	{name: "hex file", file: testKey + "\n"},
+	{name: "hex file without 0x", file: strings.TrimPrefix(testKey, "0x")},
+	{name: ".env file", file: "PORT=8080\n" + privateKeyVar + "=" + testKey + "\n"},
+	{name: "stdin", file: privateKeyVar + "=" + testKey + "\n", stdin: true},
+	{name: "missing file", file: "-", err: "no such file"},
+	{name: "invalid key", file: "0x1234\n", err: "invalid private key"},
+	{name: ".env file without key", file: "PORT=8080\n", err: "invalid private key"},
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	want, err := parsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range loadPrivateKeyTests {
+		name := filepath.Join(t.TempDir(), "key")
+		if tt.file != "-" {
+			if err := os.WriteFile(name, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		source := name
+		if tt.stdin {
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			stdin := os.Stdin
+			os.Stdin = f
+			defer func() { os.Stdin = stdin }()
+			source = "-"
+		}
+		key, err := loadPrivateKey(source)
+		switch {
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: loadPrivateKey = %v, want error containing %q", tt.name, err, tt.err)
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: loadPrivateKey: %v", tt.name, err)
+		case tt.err == "" && !key.Equal(want):
+			t.Errorf("%s: loadPrivateKey = %s, want %s", tt.name, privateKeyHex(key), testKey)
+		}
+	}
+
+	// The key itself is refused, without being echoed.
+	if _, err := loadPrivateKey(testKey); err == nil || strings.Contains(err.Error(), testKey[2:]) {
+		t.Errorf("loadPrivateKey(key) = %v, want an error without the key", err)
+	}
+}
+
+func TestPubkey(t *testing.T) {
+	key, err := parsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "public key: " + publicKeyHex(&key.PublicKey) + "\n" +
+		"address:    " + crypto.PubkeyToAddress(key.PublicKey).Hex() + "\n"
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(privateKeyVar+"="+testKey+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(privateKeyVar, "")
+	for _, args := range [][]string{{"pubkey"}, {"pubkey", ".env"}} {
+		stdout, stderr, status := runMain(t, dir, args...)
+		if status != 0 || stdout != want {
+			t.Errorf("%s: status %d, output:\n%s%s\nwant:\n%s", strings.Join(args, " "), status, stdout, stderr, want)
+		}
+	}
+
+	t.Setenv(privateKeyVar, testKey)
+	stdout, stderr, status := runMain(t, t.TempDir(), "pubkey")
+	if status != 0 || stdout != want {
+		t.Errorf("pubkey with $%s: status %d, output:\n%s%s\nwant:\n%s", privateKeyVar, status, stdout, stderr, want)
+	}
+}
+
+func TestEncryptKey(t *testing.T) {
+	key, err := parsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := encryptKey(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), testKey[2:]) {
+		t.Errorf("keystore holds the key in the clear:\n%s", data)
+	}
+	k, err := keystore.DecryptKey(data, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !k.PrivateKey.Equal(key) {
+		t.Errorf("decrypted key = %s, want %s", privateKeyHex(k.PrivateKey), testKey)
+	}
+	if k.Address != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Errorf("keystore address = %s, want %s", k.Address, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	if _, err := keystore.DecryptKey(data, "wrong"); err == nil {
+		t.Errorf("keystore decrypts with the wrong passphrase")
+	}
+}
@@ -6,15 +6,184 @@
 //
 // Usage:
 //
-//	go run ithub.com/t-0-network/provider-starter-go/new you_package_name
+//	go run github.com/t-0-network/provider-starter-go@latest [flags] dstmod [dir]
+//
+// Dstmod must be a valid module path. The new module is created in dir,
+// which can also be given as -dir and defaults to the last element of
+// dstmod, without any major version suffix, in the current directory.
+//
+// Other commands manage provider keys outside of a new project:
+//
+//	go run github.com/t-0-network/provider-starter-go@latest keygen [-o file]
+//	go run github.com/t-0-network/provider-starter-go@latest pubkey [file | -]
+//
+// Keygen writes a new key pair, in .env format, to standard output or file.
+// Pubkey prints the public key and Ethereum-style address for a private key,
+// read from the named file or standard input, $PROVIDER_PRIVATE_KEY, or the
+// .env file.
+//
+// The new module records the template version it was created from in
+// .provider-starter.json, along with the SHA-256 checksum of every file
+// generated from the template, the provider public key, and the version
+// of this tool. Verify reports the generated files that were modified
+// or deleted since, exiting with status 1 if there are any:
+//
+//	go run github.com/t-0-network/provider-starter-go@latest verify [dir]
+//
+// Upgrade merges the changes made to the template
+// since that version into the module in dir, which defaults to the current
+// directory:
+//
+//	go run github.com/t-0-network/provider-starter-go@latest upgrade [-version version] [-dry-run] [dir]
+//
+// Files changed both in the module and in the template are merged line by
+// line, with conflict markers where the changes overlap.
+//
+// The flags are:
+//
+//	-template module[@version] | dir
+//		Scaffold from the given template module, optionally pinned to a
+//		version, or from a local directory holding a copy of the template.
+//		The default is the template module published with this tool.
+//	-version version
+//		Download the given version of the template module instead of latest.
+//	-layout auto | module | workspace | package
+//		How to create the new module when dir is inside an existing module
+//		or go.work workspace. With module, the new module is nested in
+//		the enclosing one. With workspace, it is also added to the enclosing
+//		go.work file with a use directive. With package, the template becomes
+//		packages of the enclosing module: dstmod must be the import path of
+//		dir in it, and the template's requirements are merged into the
+//		enclosing module's go.mod and go.sum instead of writing new ones.
+//		The default, auto, is workspace inside a workspace and module
+//		otherwise.
+//	-offline
+//		Scaffold from the copy of the default template embedded in this
+//		tool, without downloading anything. The embedded copy is also used
+//		when downloading the default template fails.
+//	-dry-run
+//		Print each file that would be written, with a unified diff of the
+//		rewrites made to it, without creating anything.
+//	-dir dir
+//		Create the new module in dir, as an alternative to the dir argument.
+//	-package-name name
+//		Name the root package of the new module name, renaming its uses in
+//		the files importing it. The default is the last element of dstmod,
+//		lower-cased and without the characters not allowed in identifiers,
+//		so that github.com/acme/pay-out has package name payout.
+//	-force
+//	-skip-existing
+//	-merge
+//		Scaffold into a non-empty target directory. When a template file
+//		already exists there with different contents, -force overwrites it,
+//		-skip-existing keeps it, and -merge keeps it and writes the template
+//		version next to it with a .new suffix. An existing .env is always
+//		kept, with only the variables it lacks added to it; in particular,
+//		a provider key configured in it is never replaced.
+//	-json
+//		Print the result as a JSON object on standard output: the target
+//		directory, module path, public key, template version, the files
+//		written with the action taken for each, the other references to the
+//		template module rewritten, warnings, and the results of the -post
+//		steps. An error is printed as an object holding the error
+//		kind, message and exit status under "error". Progress is still
+//		logged to standard error. The exit status is 2 for invalid arguments,
+//		3 if downloading the template failed, 4 if the target directory
+//		cannot be scaffolded into, 5 if a template, .env or go.mod file
+//		cannot be parsed, 6 if generating, loading or storing the key
+//		failed, and 1 for other errors, including failed -post steps.
+//		The exit status is the same without -json.
+//	-post steps
+//		After scaffolding, run the comma-separated steps in the new module,
+//		in this order, stopping at the first one that fails:
+//		tidy runs go mod tidy; fmt checks that gofmt -l lists no files;
+//		vet runs go vet ./...; build runs go build ./...; and git creates
+//		a git repository with an initial commit of everything except .env
+//		and keystore.json. The step "all" selects every step. A report of
+//		the steps is printed at the end, with the output of a failed step,
+//		and the exit status is 1 if one failed.
+//	-i
+//		Prompt for the module path, target directory, corridor, port,
+//		endpoint, features and key. This is the default when no arguments are given
+//		and the standard input is a terminal.
+//	-import-key file
+//		Use an existing private key instead of generating a new one.
+//		The file holds the hex-encoded key, or is a .env file setting
+//		PROVIDER_PRIVATE_KEY; - reads it from standard input. The key
+//		itself is not accepted, as it would show in ps output and shell
+//		history.
+//	-keystore
+//		Write the private key to keystore.json, encrypted with a passphrase
+//		taken from $PROVIDER_KEYSTORE_PASSPHRASE or prompted for, and refer
+//		to it as PROVIDER_KEYSTORE_PATH in .env instead of storing the key
+//		in plain text.
+//	-port port
+//		Default port the provider service listens on. The default is 8080.
+//	-endpoint sandbox | production | url
+//		The t-0 Network API endpoint to connect to. The default is sandbox.
+//	-currency code
+//		Pay-out currency to publish quotes for. The default is EUR.
+//	-payment-method method
+//		Pay-out payment method to publish quotes for: one of the
+//		common.PaymentMethodType names of the provider SDK, such as SEPA,
+//		ACH or PIX. The default is SEPA.
+//	-features list
+//		Include only the comma-separated features of the template:
+//		payout, the PayOut handler and published pay-out quotes;
+//		payin, the UpdatePayment handler and published pay-in quotes;
+//		quotes, the GetQuote probe of the published quotes;
+//		ledger, the AppendLedgerEntries handler; limits, the UpdateLimit
+//		handler; and aml, the ApprovePaymentQuotes handler.
+//		The default is all of them. Handlers left out answer with
+//		connect.CodeUnimplemented.
+//	-go version
+//	-toolchain name
+//		Set the go or toolchain line of the new go.mod file.
+//		A toolchain of none removes the template's toolchain line.
+//		The go version may not be below the go line of the template's go.mod.
+//	-sdk version
+//		Require this version of github.com/t-0-network/provider-sdk-go
+//		instead of the template's. A query other than a semantic version,
+//		such as latest, a branch name or a commit hash, is resolved with
+//		go mod download. Run go mod tidy (or use -post tidy) afterwards to
+//		update go.sum.
+//	-replace old[@v]=new[@v]
+//		Add a replace directive to the new go.mod file, as go mod edit
+//		-replace does; the flag may be repeated. A local directory new,
+//		such as an SDK checkout, is taken relative to the current directory
+//		and written relative to the new module.
+//
+// Replace directives of the template that refer to modules inside the
+// template are rewritten to refer to the new module, or to the
+// directory of the replacement in it.
+//
+// The new module always gets .gitignore and .dockerignore files that exclude
+// .env, keystore.json and build outputs; entries missing from existing files
+// are added to them. Scaffolding into a git repository that already tracks
+// the .env or keystore.json file in dir is refused.
+//
+// Template files with a .tmpl suffix are rendered with text/template,
+// using variables set from the flags, and written without the suffix.
+// See templateVars for the list of variables. Template files and regions
+// belonging to features are annotated with comments; see selectFeatures.
+//
+// Binary files are copied unchanged, executable files stay executable,
+// and symbolic links are recreated rather than followed. Files matched by
+// the patterns in the template's .starter-verbatim file, such as golden
+// files in testdata, are copied unchanged too; see readVerbatim.
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/hex"
+	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
 	"go/parser"
 	"go/token"
 	"io/fs"
@@ -24,145 +193,616 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/joho/godotenv"
 	"github.com/t-0-network/provider-starter-go/internal"
+	"github.com/t-0-network/provider-starter-go/internal/diff"
 	"github.com/t-0-network/provider-starter-go/internal/edit"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 )
 
+var (
+	templateFlag = flag.String("template", "", "template `module[@version]` or local directory to scaffold from")
+	versionFlag  = flag.String("version", "", "template module `version` to download (default latest)")
+	layoutFlag   = flag.String("layout", "auto", "`layout` of the new module in an enclosing module or workspace: auto, module, workspace or package")
+	offlineFlag  = flag.Bool("offline", false, "scaffold from the copy of the template embedded in this tool, without downloading it")
+	dryRunFlag   = flag.Bool("dry-run", false, "print the files that would be written and the rewrites made, without writing anything")
+
+	dirFlag         = flag.String("dir", "", "`directory` to create the new module in, instead of the dir argument")
+	packageNameFlag = flag.String("package-name", "", "`name` of the root package of the new module (default derived from the module path)")
+
+	forceFlag        = flag.Bool("force", false, "overwrite existing files in a non-empty target directory")
+	skipExistingFlag = flag.Bool("skip-existing", false, "keep existing files in a non-empty target directory")
+	mergeFlag        = flag.Bool("merge", false, "write template files that conflict with existing files in a non-empty target directory as file.new")
+	postFlag         = flag.String("post", "", "comma-separated `steps` to run in the new module: tidy, fmt, vet, build, git, or all")
+	jsonFlag         = flag.Bool("json", false, "print the result, or the error, as a JSON object on standard output")
+
+	interactiveFlag = flag.Bool("i", false, "prompt for the settings interactively")
+	keystoreFlag    = flag.Bool("keystore", false, "write the private key to an encrypted "+keystoreFile+" instead of .env")
+	importKeyFlag   = flag.String("import-key", "", "use the private key from `file` (key or .env file, or - for stdin) instead of generating one")
+
+	portFlag          = flag.String("port", "8080", "default `port` the provider service listens on")
+	endpointFlag      = flag.String("endpoint", "sandbox", "t-0 Network API `endpoint`: sandbox, production, or a URL")
+	currencyFlag      = flag.String("currency", "EUR", "pay-out `currency` to publish quotes for")
+	paymentMethodFlag = flag.String("payment-method", "SEPA", "pay-out payment `method` to publish quotes for, such as SEPA or PIX")
+	featuresFlag      = flag.String("features", "all", "comma-separated `features` to include: payout, payin, quotes, ledger, limits, aml, or all")
+
+	goFlag        = flag.String("go", "", "Go `version` for the go line of the new go.mod (default the template's)")
+	toolchainFlag = flag.String("toolchain", "", "`name` for the toolchain line of the new go.mod, such as go1.25.1, or none to remove it")
+	sdkFlag       = flag.String("sdk", "", "provider SDK `version` to require: a semantic version, or a query such as latest or a branch name")
+	replaceFlags  replaceList
+)
+
+func init() {
+	flag.Var(&replaceFlags, "replace", "add a replace directive `old[@v]=new[@v]` to the new go.mod, such as a local SDK checkout; repeatable")
+}
+
+// commands are the subcommands, run as "provider-starter-go command [args]".
+var commands = map[string]func(args []string){
+	"keygen":  cmdKeygen,
+	"pubkey":  cmdPubkey,
+	"upgrade": cmdUpgrade,
+	"verify":  cmdVerify,
+}
+
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest dstmod\n")
-	os.Exit(2)
+	fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest [flags] dstmod [dir]\n")
+	fmt.Fprintf(os.Stderr, "       go run github.com/t-0-network/provider-starter-go@latest keygen [-o file]\n")
+	fmt.Fprintf(os.Stderr, "       go run github.com/t-0-network/provider-starter-go@latest pubkey [file | -]\n")
+	fmt.Fprintf(os.Stderr, "       go run github.com/t-0-network/provider-starter-go@latest upgrade [flags] [dir]\n")
+	fmt.Fprintf(os.Stderr, "       go run github.com/t-0-network/provider-starter-go@latest verify [dir]\n")
+	flag.PrintDefaults()
+	if *jsonFlag {
+		printJSON(errorOutput{newErrorResult(errUsage, errors.New("usage: go run github.com/t-0-network/provider-starter-go@latest [flags] dstmod [dir]"))})
+	}
+	os.Exit(errUsage.exitCode())
 }
 
 func main() {
 	log.SetPrefix("new: ")
 	log.SetFlags(0)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			log.SetPrefix(os.Args[1] + ": ")
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
 
+	var key *ecdsa.PrivateKey
+	if *importKeyFlag != "" {
+		var err error
+		if key, err = loadPrivateKey(*importKeyFlag); err != nil {
+			fatal(errKey, err)
+		}
+	}
+	if *interactiveFlag || len(args) == 0 && isTerminal() {
+		w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		var err error
+		var wkey *ecdsa.PrivateKey
+		if args, wkey, err = w.run(key == nil); err != nil {
+			fatal(errUsage, err)
+		}
+		if wkey != nil {
+			key = wkey
+		}
+	}
+
 	if len(args) < 1 || len(args) > 2 {
 		usage()
 	}
+	if *jsonFlag && *dryRunFlag {
+		fatalf(errUsage, "cannot use -json with -dry-run")
+	}
 
 	dstMod := args[0]
-	dir := "." + string(filepath.Separator) + path.Base(dstMod)
+	if err := checkModulePath(dstMod); err != nil {
+		if s := suggestModulePath(dstMod); s != "" {
+			fatalf(errUsage, "%v\n\tdid you mean %q?", err, s)
+		}
+		fatal(errUsage, err)
+	}
+	dir := "." + string(filepath.Separator) + projectName(dstMod)
+	switch {
+	case len(args) == 2 && *dirFlag != "" && args[1] != *dirFlag:
+		fatalf(errUsage, "target directory given twice, as %s and as -dir %s", args[1], *dirFlag)
+	case len(args) == 2:
+		dir = args[1]
+	case *dirFlag != "":
+		dir = *dirFlag
+	}
+
+	vars, err := templateVars(dstMod, dir)
+	if err != nil {
+		fatal(errUsage, err)
+	}
+
+	var warnings []string
+	pl, err := placeModule(dir, dstMod)
+	if err != nil {
+		fatal(errUsage, err)
+	}
+	if pl.layout == layoutModule && pl.parent.mod != "" && layout(*layoutFlag) == layoutAuto {
+		w := fmt.Sprintf("%s is inside module %s; creating a nested module (use -layout package to add packages to %s instead)", dir, pl.parent.mod, pl.parent.mod)
+		log.Print(w)
+		warnings = append(warnings, w)
+	}
+	if pl.layout == layoutPackage {
+		warnings = append(warnings, "the Dockerfile builds from the directory of the new packages; adjust it to build from the root of module "+pl.parent.mod)
+	}
+
+	var src *source
+	if *offlineFlag {
+		if *templateFlag != "" || *versionFlag != "" {
+			fatalf(errUsage, "cannot use -offline with -template or -version")
+		}
+		src, err = embeddedTemplate()
+	} else {
+		src, err = loadTemplate(*templateFlag, *versionFlag)
+		var ke *kindError
+		if errors.As(err, &ke) && ke.kind == errNetwork && *templateFlag == "" && *versionFlag == "" {
+			// Fall back to the embedded copy of the default template.
+			w := fmt.Sprintf("downloading the template failed; using the copy embedded in this tool instead:\n%v", err)
+			log.Print(w)
+			warnings = append(warnings, w)
+			src, err = embeddedTemplate()
+		}
+	}
+	if err != nil {
+		fatal(errOther, err)
+	}
+	if err := checkGoVersion(src.fsys, vars["GoVersion"]); err != nil {
+		fatal(errUsage, err)
+	}
+
+	policy, err := existingPolicy()
+	if err != nil {
+		fatal(errUsage, err)
+	}
+	post, err := parsePost(*postFlag)
+	if err != nil {
+		fatal(errUsage, err)
+	}
+	if vars["SDKVersion"] != "" && !slices.ContainsFunc(post, func(s postStep) bool { return s.name == "tidy" }) {
+		w := "go.sum lacks the checksums for the -sdk version; run go mod tidy (or use -post tidy) before building"
+		log.Print(w)
+		warnings = append(warnings, w)
+	}
 
-	// Dir must not exist or must be an empty directory.
+	// Unless told what to do with existing files,
+	// dir must not exist or must be an empty directory.
 	de, err := os.ReadDir(dir)
-	if err == nil && len(de) > 0 {
-		log.Fatalf("target directory %s exists and is non-empty", dir)
+	if err == nil && len(de) > 0 && policy == failExisting {
+		fatalf(errTarget, "target directory %s exists and is non-empty; use -force, -skip-existing or -merge to scaffold into it", dir)
 	}
 	needMkdir := err != nil
+	if err := checkSecretsTracked(dir); err != nil {
+		fatal(errTarget, err)
+	}
 
-	srcMod, _ := strings.CutSuffix(reflect.TypeOf(internal.Dummy{}).PkgPath(), "/internal")
-	srcMod += "/template"
-	srcModVers := srcMod + "@latest"
-	var stdout, stderr bytes.Buffer
-	cmd := exec.Command("go", "mod", "download", "-json", srcModVers)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	if err := cmd.Run(); err != nil {
-		log.Fatalf("go mod download -json %s: %v\n%s%s", srcModVers, err, stderr.Bytes(), stdout.Bytes())
+	// An existing provider key in dir/.env is never replaced.
+	oldEnv, err := readEnv(dir)
+	if err != nil {
+		fatal(errParse, err)
+	}
+	keepKey := oldEnv[privateKeyVar] != "" && oldEnv[privateKeyVar] != envExamplePlaceholder ||
+		oldEnv[keystorePathVar] != ""
+	if keepKey {
+		env := filepath.Join(dir, ".env")
+		oldKey, err := parsePrivateKey(oldEnv[privateKeyVar])
+		switch {
+		case *keystoreFlag:
+			fatalf(errKey, "%s already configures the provider key; refusing to replace it with a keystore", env)
+		case err == nil && (key == nil || key.Equal(oldKey)):
+			key = oldKey
+		case key != nil:
+			fatalf(errKey, "%s already configures a different provider key; refusing to replace it", env)
+		}
 	}
 
-	var info struct {
-		Dir string
+	sc := &scaffold{srcMod: src.mod, fsys: src.fsys, dstMod: dstMod, vars: vars}
+
+	if *dryRunFlag {
+		if err := dryRun(sc, pl, policy); err != nil {
+			fatal(walkErrorKind(err), err)
+		}
+		return
 	}
-	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
-		log.Fatalf("go mod download -json %s: invalid JSON output: %v\n%s%s", srcMod, err, stderr.Bytes(), stdout.Bytes())
+
+	var passphrase string
+	if *keystoreFlag {
+		if passphrase, err = readPassphrase(); err != nil {
+			fatal(errKey, err)
+		}
+	}
+	if key == nil && !keepKey {
+		if key, err = crypto.GenerateKey(); err != nil {
+			fatal(errKey, err)
+		}
+	}
+	publicKey := oldEnv["PROVIDER_PUBLIC_KEY"]
+	if key != nil {
+		publicKey = publicKeyHex(&key.PublicKey)
 	}
 
 	if needMkdir {
 		if err := os.MkdirAll(dir, 0777); err != nil {
-			log.Fatal(err)
+			fatal(errOther, err)
 		}
 	}
 
-	// Copy from module cache into new directory, making edits as needed.
-	filepath.WalkDir(info.Dir, func(src string, d fs.DirEntry, err error) error {
-		if err != nil {
-			log.Fatal(err)
-		}
-		rel, err := filepath.Rel(info.Dir, src)
-		if err != nil {
-			log.Fatal(err)
+	// Copy from template directory into new directory, making edits as needed.
+	var reps []replacement
+	var envExample []byte
+	ignores := map[string][]byte{}
+	m := newManifest(src, sc, publicKey)
+	res := &result{Dir: dir, Module: dstMod, PublicKey: publicKey, Template: m.Template, Rewrites: []rewriteResult{}, Warnings: append([]string{}, warnings...)}
+	err = sc.walk(func(f *templateFile) error {
+		reps = append(reps, f.reps...)
+		if pl.capture(f) {
+			return nil
 		}
-		dst := filepath.Join(dir, rel)
-		if d.IsDir() {
-			if err := os.MkdirAll(dst, 0777); err != nil {
-				log.Fatal(err)
-			}
+		if isIgnoreFile(f.rel) {
+			ignores[f.rel] = f.data
 			return nil
 		}
-
-		data, err := os.ReadFile(src)
+		if f.rel == ".env.example" {
+			envExample = f.data
+		}
+		action, err := placeFile(filepath.Join(dir, f.rel), f, policy, false)
+		if action != "" {
+			log.Printf("%s: %s", f.rel, action)
+		}
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		res.addFile(filepath.ToSlash(f.rel), action)
+		switch action {
+		case "", "exists, unchanged", "exists, overwritten":
+			return m.addTemplateFile(f)
+		}
+		// The existing file was kept by -skip-existing or -merge;
+		// it was not generated from the template, so verify must not check it.
+		return nil
+	})
+	if err != nil {
+		fatal(walkErrorKind(err), err)
+	}
 
-		isRoot := !strings.Contains(rel, string(filepath.Separator))
-		if strings.HasSuffix(rel, ".go") {
-			data = fixGo(data, rel, srcMod, dstMod, isRoot)
+	if err := writeEnv(dir, envExample, oldEnv, sc.vars, key, passphrase); err != nil {
+		fatal(errOther, err)
+	}
+	if passphrase != "" {
+		res.addFile(keystoreFile, "")
+	}
+	if len(oldEnv) > 0 {
+		res.addFile(".env", "exists, missing variables added")
+	} else {
+		res.addFile(".env", "")
+	}
+	actions, err := writeIgnores(dir, ignores, false)
+	if err != nil {
+		fatal(errOther, err)
+	}
+	for _, f := range ignoreFiles {
+		if a := actions[f.name]; a != "generated" {
+			log.Printf("%s: %s", f.name, a)
+			res.addFile(f.name, a)
+		} else {
+			res.addFile(f.name, "")
 		}
-		if rel == "go.mod" {
-			data = fixGoMod(data, dstMod)
+	}
+	if pl.layout == layoutPackage {
+		m.ParentModule = pl.parent.mod
+	}
+	if err := writeManifest(dir, m); err != nil {
+		fatal(errOther, err)
+	}
+	res.addFile(manifestFile, "")
+	files, err := pl.finish(false)
+	if err != nil {
+		fatal(errOther, err)
+	}
+	for _, f := range files {
+		log.Printf("%s: %s", filepath.Join(dir, filepath.FromSlash(f.Path)), f.Action)
+		res.Files = append(res.Files, f)
+	}
+	log.Printf("initialized %s in %s", dstMod, dir)
+	if len(reps) > 0 {
+		log.Printf("rewrote %d other references to %s:", len(reps), src.mod)
+		for _, r := range reps {
+			log.Printf("\t%v", r)
+			res.Rewrites = append(res.Rewrites, rewriteResult{filepath.ToSlash(r.file), r.line, r.old, r.new})
+		}
+	}
+
+	if len(post) > 0 {
+		results := runPost(dir, "Initial commit of "+dstMod, post)
+		log.Printf("post steps:")
+		ok := reportPost(results)
+		res.addPost(results)
+		if !ok {
+			if *jsonFlag {
+				for _, r := range results {
+					if r.err != nil {
+						res.Error = newErrorResult(errPostStep, fmt.Errorf("-post step %s failed: %v", r.name, r.err))
+					}
+				}
+				printJSON(res)
+			}
+			os.Exit(errPostStep.exitCode())
 		}
+	}
+	if *jsonFlag {
+		printJSON(res)
+	}
+}
 
-		if err := os.WriteFile(dst, data, 0666); err != nil {
-			log.Fatal(err)
+// dryRun reports the files that the scaffold sc would write into pl.dir,
+// with a diff of every file rewritten from the template, and the changes
+// to the enclosing module or workspace, without touching the file system.
+// Policy says what would happen to existing files.
+func dryRun(sc *scaffold, pl *placement, policy existsPolicy) error {
+	dir := pl.dir
+	out := bufio.NewWriter(os.Stdout)
+	ignores := map[string][]byte{}
+	err := sc.walk(func(f *templateFile) error {
+		if pl.capture(f) {
+			return nil
+		}
+		if isIgnoreFile(f.rel) {
+			ignores[f.rel] = f.data
+			return nil
+		}
+		dst := filepath.Join(dir, f.rel)
+		action, err := placeFile(dst, f, policy, true)
+		if err != nil {
+			return err
+		}
+		if action != "" {
+			action = ", " + action
+		}
+		d := diff.Diff(path.Join(sc.srcMod, filepath.ToSlash(f.src)), f.orig, dst, f.data)
+		switch {
+		case f.link != "":
+			fmt.Fprintf(out, "%s (symbolic link to %s%s)\n", dst, f.link, action)
+		case f.binary:
+			fmt.Fprintf(out, "%s (copied, binary%s)\n", dst, action)
+		case f.src != f.rel:
+			fmt.Fprintf(out, "%s (rendered from %s%s)\n%s", dst, f.src, action, d)
+		case d == nil:
+			fmt.Fprintf(out, "%s (copied%s)\n", dst, action)
+		default:
+			fmt.Fprintf(out, "%s (rewritten%s)\n%s", dst, action, d)
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	env := filepath.Join(dir, ".env")
+	if _, err := os.Stat(env); err == nil {
+		fmt.Fprintf(out, "%s (new variables from .env.example added, existing ones kept)\n", env)
+	} else {
+		fmt.Fprintf(out, "%s (generated from .env.example with a new key pair)\n", env)
+	}
+	actions, err := writeIgnores(dir, ignores, true)
+	if err != nil {
+		return err
+	}
+	for _, f := range ignoreFiles {
+		fmt.Fprintf(out, "%s (%s)\n", filepath.Join(dir, f.name), actions[f.name])
+	}
+	fmt.Fprintf(out, "%s (generated)\n", filepath.Join(dir, manifestFile))
+	files, err := pl.finish(true)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Fprintf(out, "%s (%s)\n", filepath.Join(dir, filepath.FromSlash(f.Path)), f.Action)
+	}
+	return out.Flush()
+}
+
+// envExamplePlaceholder is the value of PROVIDER_PRIVATE_KEY in .env.example.
+const envExamplePlaceholder = "your_private_key_here"
 
-	// TODO: extract into function
-	key, err := crypto.GenerateKey()
+// readEnv returns the variables set in dir/.env,
+// or an empty map if there is no such file.
+func readEnv(dir string) (map[string]string, error) {
+	env, err := godotenv.Read(filepath.Join(dir, ".env"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	return env, err
+}
+
+// writeEnv writes dir/.env with the settings from the template's
+// .env.example contents, the server settings from vars,
+// and the provider key pair.
+// If passphrase is not empty, the private key is written to a keystore
+// encrypted with the passphrase instead, which .env refers to.
+//
+// The variables in oldEnv, the contents of an existing dir/.env, take
+// precedence over the new ones, so that scaffolding into an existing
+// directory only adds variables to it. In particular, if oldEnv configures
+// a provider key, key must be nil or that key, and is not written.
+func writeEnv(dir string, envExample []byte, oldEnv, vars map[string]string, key *ecdsa.PrivateKey, passphrase string) error {
+	values, err := godotenv.UnmarshalBytes(envExample)
 	if err != nil {
-		log.Fatal(err)
+		return withKind(errParse, fmt.Errorf(".env.example: %v", err))
 	}
-	values, err := godotenv.Read(path.Join(dir, ".env.example"))
+
+	// Templates without an .env.example.tmpl cannot render these.
+	values["PORT"] = vars["Port"]
+	values["TZERO_ENDPOINT"] = vars["Endpoint"]
+
+	switch {
+	case key == nil:
+		// Keep the key configured in oldEnv.
+	case passphrase != "":
+		data, err := encryptKey(key, passphrase)
+		if err != nil {
+			return withKind(errKey, err)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, keystoreFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return withKind(errKey, err)
+		}
+		if _, err := f.Write(data); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		delete(values, privateKeyVar)
+		values[keystorePathVar] = keystoreFile
+		values["PROVIDER_PUBLIC_KEY"] = publicKeyHex(&key.PublicKey)
+	default:
+		values[privateKeyVar] = privateKeyHex(key)
+		values["PROVIDER_PUBLIC_KEY"] = publicKeyHex(&key.PublicKey)
+	}
+	for k, v := range oldEnv {
+		if key != nil && (k == privateKeyVar || k == keystorePathVar || k == "PROVIDER_PUBLIC_KEY") {
+			// A placeholder, or the same key.
+			continue
+		}
+		values[k] = v
+	}
+
+	// Create .env readable only by the owner before writing any secrets to it.
+	env := filepath.Join(dir, ".env")
+	f, err := os.OpenFile(env, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(env, 0600); err != nil {
+		return err
+	}
+	return godotenv.Write(values, env)
+}
+
+// defaultTemplate returns the module path of the template published
+// alongside this tool.
+func defaultTemplate() string {
+	mod, _ := strings.CutSuffix(reflect.TypeOf(internal.Dummy{}).PkgPath(), "/internal")
+	return mod + "/template"
+}
+
+// A source is a copy of the template to scaffold from.
+type source struct {
+	mod     string // module path of the template
+	version string // module version, or "" for a local directory
+	sum     string // checksum of the module, or "" for a local directory
+	dir     string // directory holding the template files, or "" for the embedded copy
+	fsys    fs.FS  // the template files
+}
+
+// loadTemplate locates the template named by spec.
+// The spec is either a local directory, a module path, or module@version;
+// an empty spec means the default template. The version, if non-empty,
+// selects the module version to download; it defaults to latest.
+func loadTemplate(spec, version string) (*source, error) {
+	if spec != "" && (build.IsLocalImport(spec) || filepath.IsAbs(spec)) {
+		if version != "" {
+			return nil, withKind(errUsage, fmt.Errorf("cannot use -version with local template directory %s", spec))
+		}
+		data, err := os.ReadFile(filepath.Join(spec, "go.mod"))
+		if err != nil {
+			return nil, withKind(errUsage, fmt.Errorf("reading template: %v", err))
+		}
+		mod := modfile.ModulePath(data)
+		if mod == "" {
+			return nil, withKind(errParse, fmt.Errorf("reading template: %s has no module statement", filepath.Join(spec, "go.mod")))
+		}
+		return &source{mod: mod, dir: spec, fsys: os.DirFS(spec)}, nil
+	}
+
+	srcMod := spec
+	if srcMod == "" {
+		srcMod = defaultTemplate()
+	}
+	if mod, vers, ok := strings.Cut(srcMod, "@"); ok {
+		if version != "" && version != vers {
+			return nil, withKind(errUsage, fmt.Errorf("conflicting template versions %s and -version %s", srcMod, version))
+		}
+		srcMod, version = mod, vers
+	}
+	if version == "" {
+		version = "latest"
 	}
 
-	values["PROVIDER_PRIVATE_KEY"] = fmt.Sprintf("0x%s", hex.EncodeToString(crypto.FromECDSA(key)))
-	values["PROVIDER_PUBLIC_KEY"] = fmt.Sprintf("0x%s", hex.EncodeToString(crypto.FromECDSAPub(&key.PublicKey)))
-	err = godotenv.Write(values, path.Join(dir, ".env"))
+	info, err := download(srcMod + "@" + version)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	log.Printf("initialized %s in %s", dstMod, dir)
+	return &source{mod: srcMod, version: info.Version, sum: info.Sum, dir: info.Dir, fsys: os.DirFS(info.Dir)}, nil
+}
+
+// A downloadInfo describes a module downloaded by go mod download.
+type downloadInfo struct {
+	Dir     string
+	Version string
+	Sum     string
+}
+
+// download downloads the module version named by modQuery, a module path
+// and version query such as latest or a branch name, into the module cache.
+func download(modQuery string) (*downloadInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", "mod", "download", "-json", modQuery)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, withKind(errNetwork, fmt.Errorf("go mod download -json %s: %v\n%s%s", modQuery, err, stderr.Bytes(), stdout.Bytes()))
+	}
+	info := new(downloadInfo)
+	if err := json.Unmarshal(stdout.Bytes(), info); err != nil {
+		return nil, fmt.Errorf("go mod download -json %s: invalid JSON output: %v\n%s%s", modQuery, err, stderr.Bytes(), stdout.Bytes())
+	}
+	return info, nil
 }
 
-// fixGo rewrites the Go source in data to replace srcMod with dstMod.
+// fixGo rewrites the Go source in data to replace srcMod with dstMod,
+// both in import declarations and in string literals and comments,
+// returning the replacements made outside import declarations.
 // isRoot indicates whether the file is in the root directory of the module,
-// in which case we also update the package name.
-func fixGo(data []byte, file string, srcMod, dstMod string, isRoot bool) []byte {
+// in which case we also rename the package to dstName, along with the uses
+// of the package name in files importing the root package.
+// The scope holds the package-level names declared in the file's
+// directory, which the renamed package name must not collide with.
+func fixGo(data []byte, file string, srcMod, dstMod, dstName string, isRoot bool, scope map[string]bool) ([]byte, []replacement, error) {
 	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, file, data, parser.ImportsOnly)
+	f, err := parser.ParseFile(fset, file, data, parser.ParseComments)
 	if err != nil {
-		log.Fatalf("parsing source module:\n%s", err)
+		return nil, nil, fmt.Errorf("parsing source module:\n%s", err)
 	}
 
-	buf := edit.NewBuffer(data)
-	at := func(p token.Pos) int {
-		return fset.File(p).Offset(p)
-	}
+	buf := edit.NewFileBuffer(fset.File(f.Pos()), data)
 
 	srcName := path.Base(srcMod)
-	dstName := path.Base(dstMod)
 	if isRoot {
 		if name := f.Name.Name; name == srcName || name == srcName+"_test" {
 			dname := dstName + strings.TrimPrefix(name, srcName)
 			if !token.IsIdentifier(dname) {
-				log.Fatalf("%s: cannot rename package %s to package %s: invalid package name", file, name, dname)
+				return nil, nil, fmt.Errorf("%s: cannot rename package %s to package %s: invalid package name", file, name, dname)
+			}
+			if err := buf.TryReplacePos(f.Name.Pos(), f.Name.End(), dname); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
 			}
-			buf.Replace(at(f.Name.Pos()), at(f.Name.End()), dname)
 		}
 	}
 
@@ -173,38 +813,167 @@ func fixGo(data []byte, file string, srcMod, dstMod string, isRoot bool) []byte
 		}
 		if path == srcMod {
 			if srcName != dstName && spec.Name == nil {
-				// Add package rename because source code uses original name.
-				// The renaming looks strange, but template authors are unlikely to
-				// create a template where the root package is imported by packages
-				// in subdirectories, and the renaming at least keeps the code working.
-				// A more sophisticated approach would be to rename the uses of
-				// the package identifier in the file too, but then you have to worry about
-				// name collisions, and given how unlikely this is, it doesn't seem worth
-				// trying to clean up the file that way.
-				buf.Insert(at(spec.Path.Pos()), srcName+" ")
+				// The source code refers to the package by its original name.
+				// Rename the uses of the package identifier in the file,
+				// unless the new name would collide with another name in the file,
+				// in which case keep the original name as an import alias.
+				uses, ok := packageUses(f, srcName, dstName, scope)
+				if ok {
+					for _, id := range uses {
+						if err := buf.TryReplacePos(id.Pos(), id.End(), dstName); err != nil {
+							return nil, nil, fmt.Errorf("%s: %v", file, err)
+						}
+					}
+				} else {
+					log.Printf("%s: importing %s as %s, because the name %s is already in use", file, dstMod, srcName, dstName)
+					if err := buf.TryInsertPos(spec.Path.Pos(), srcName+" "); err != nil {
+						return nil, nil, fmt.Errorf("%s: %v", file, err)
+					}
+				}
 			}
 			// Change import path to dstMod
-			buf.Replace(at(spec.Path.Pos()), at(spec.Path.End()), strconv.Quote(dstMod))
+			if err := buf.TryReplacePos(spec.Path.Pos(), spec.Path.End(), strconv.Quote(dstMod)); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
+			}
 		}
 		if strings.HasPrefix(path, srcMod+"/") {
 			// Change import path to begin with dstMod
-			buf.Replace(at(spec.Path.Pos()), at(spec.Path.End()), strconv.Quote(strings.Replace(path, srcMod, dstMod, 1)))
+			if err := buf.TryReplacePos(spec.Path.Pos(), spec.Path.End(), strconv.Quote(strings.Replace(path, srcMod, dstMod, 1))); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
+			}
 		}
 	}
-	return buf.Bytes()
-}
+	// Rewrite the other references to the module, in string literals,
+	// comments, and directives such as //go:generate and //go:embed.
+	imports := make(map[*ast.BasicLit]bool)
+	for _, spec := range f.Imports {
+		imports[spec.Path] = true
+	}
+	var reps []replacement
+	fix := func(n ast.Node) error {
+		r, err := fixModPath(buf, data, buf.Offset(n.Pos()), buf.Offset(n.End()), file, srcMod, dstMod)
+		reps = append(reps, r...)
+		return err
+	}
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			if err := fix(c); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	var ferr error
+	ast.Inspect(f, func(n ast.Node) bool {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING && !imports[lit] && ferr == nil {
+			ferr = fix(lit)
+		}
+		return ferr == nil
+	})
+	if ferr != nil {
+		return nil, nil, ferr
+	}
 
-// fixGoMod rewrites the go.mod content in data to add a module
-// statement for dstMod.
-func fixGoMod(data []byte, dstMod string) []byte {
-	f, err := modfile.ParseLax("go.mod", data, nil)
+	new, err := buf.Apply()
 	if err != nil {
-		log.Fatalf("parsing source module:\n%s", err)
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
 	}
-	f.AddModuleStmt(dstMod)
-	new, err := f.Format()
+
+	// Rewritten import paths may no longer be in sorted order.
+	// Keep a gofmt-formatted file formatted.
+	if old, err := format.Source(data); err == nil && bytes.Equal(old, data) {
+		if formatted, err := format.Source(new); err == nil {
+			new = formatted
+		}
+	}
+	return new, reps, nil
+}
+
+// packageUses returns the identifiers in f that refer to the imported
+// package named name, for renaming to newName.
+// It reports false if renaming them could change the meaning of the file,
+// because newName is not a valid identifier, is already declared or used
+// in the file, is declared in another file of the package, as listed in
+// scope, or is the name of another import.
+func packageUses(f *ast.File, name, newName string, scope map[string]bool) ([]*ast.Ident, bool) {
+	if !token.IsIdentifier(newName) || scope[newName] {
+		return nil, false
+	}
+	for _, spec := range f.Imports {
+		if importName(spec) == newName {
+			return nil, false
+		}
+	}
+
+	// Inspect the declarations, skipping the package clause,
+	// which names this package, not the imported one.
+	var uses []*ast.Ident
+	ok := true
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// A qualifier that is not resolved to a declaration
+			// in the file refers to an import.
+			if id, isIdent := n.X.(*ast.Ident); isIdent && id.Name == name && id.Obj == nil {
+				uses = append(uses, id)
+				return false
+			}
+			// The selected name lives in another scope
+			// and cannot collide with newName.
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			if n.Name == newName {
+				ok = false
+			}
+		}
+		return ok
+	}
+	for _, decl := range f.Decls {
+		ast.Inspect(decl, visit)
+	}
+	if !ok {
+		return nil, false
+	}
+	return uses, true
+}
+
+// packageNames adds the package-level names declared in f to names.
+func packageNames(f *ast.File, names map[string]bool) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names[d.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, id := range spec.Names {
+						names[id.Name] = true
+					}
+				case *ast.TypeSpec:
+					names[spec.Name.Name] = true
+				}
+			}
+		}
+	}
+}
+
+// importName returns the name by which the import spec is referred to
+// in the file: its explicit name, or else the last element of its path,
+// ignoring a major version suffix.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	p, err := strconv.Unquote(spec.Path.Value)
 	if err != nil {
-		return data
+		return ""
+	}
+	if prefix, _, ok := module.SplitPathVersion(p); ok && prefix != p {
+		p = prefix
 	}
-	return new
+	return path.Base(p)
 }
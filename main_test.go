@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// TestMain runs the tool itself instead of the tests when STARTER_TEST_MAIN
+// is set, so that runMain can run it in a subprocess.
+func TestMain(m *testing.M) {
+	if os.Getenv("STARTER_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the tool with args in dir
+// and returns its standard output, standard error and exit status.
+func runMain(t *testing.T, dir string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "STARTER_TEST_MAIN=1")
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	var ee *exec.ExitError
+	switch {
+	case errors.As(err, &ee):
+		status = ee.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+var renameTests = []struct {
+	name  string
+	files map[string]string // template files besides go.mod and tmpl.go
+	file  string            // file to check
+	want  string            // text the file must contain
+}{
+	{
+		name: "rename",
+		files: map[string]string{
+			"sub/a.go": "package sub\n\nimport \"example.com/tmpl\"\n\nvar _ = tmpl.F\n",
+		},
+		file: "sub/a.go",
+		want: "import \"example.com/acme/payout\"\n\nvar _ = payout.F\n",
+	},
+	{
+		name: "collision in file",
+		files: map[string]string{
+			"sub/a.go": "package sub\n\nimport \"example.com/tmpl\"\n\nvar _ = tmpl.F\n\nvar payout = 1\n",
+		},
+		file: "sub/a.go",
+		want: "import tmpl \"example.com/acme/payout\"\n\nvar _ = tmpl.F\n",
+	},
+	{
+		name: "collision in sibling file",
+		files: map[string]string{
+			"sub/a.go": "package sub\n\nimport \"example.com/tmpl\"\n\nvar _ = tmpl.F\n",
+			"sub/d.go": "package sub\n\nvar payout = 1\n",
+		},
+		file: "sub/a.go",
+		want: "import tmpl \"example.com/acme/payout\"\n\nvar _ = tmpl.F\n",
+	},
+	{
+		name: "collision in sibling template file",
+		files: map[string]string{
+			"sub/a.go":      "package sub\n\nimport \"example.com/tmpl\"\n\nvar _ = tmpl.F\n",
+			"sub/d.go.tmpl": "package sub\n\nfunc payout() string { return {{printf \"%q\" .Port}} }\n",
+		},
+		file: "sub/a.go",
+		want: "import tmpl \"example.com/acme/payout\"\n\nvar _ = tmpl.F\n",
+	},
+	{
+		name: "method in sibling file",
+		files: map[string]string{
+			"sub/a.go": "package sub\n\nimport \"example.com/tmpl\"\n\nvar _ = tmpl.F\n",
+			"sub/d.go": "package sub\n\ntype T int\n\nfunc (T) payout() {}\n",
+		},
+		file: "sub/a.go",
+		want: "import \"example.com/acme/payout\"\n\nvar _ = payout.F\n",
+	},
+}
+
+// testScaffold returns a scaffold of the module example.com/acme/payout
+// from a template module example.com/tmpl with the given files.
+func testScaffold(files map[string]string) *scaffold {
+	fsys := fstest.MapFS{
+		"go.mod":  {Data: []byte("module example.com/tmpl\n\ngo 1.25\n")},
+		"tmpl.go": {Data: []byte("package tmpl\n\nfunc F() {}\n")},
+	}
+	for name, data := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return &scaffold{
+		srcMod: "example.com/tmpl",
+		fsys:   fsys,
+		dstMod: "example.com/acme/payout",
+		vars: map[string]string{
+			"PackageName": "payout",
+			"Port":        "8080",
+			"Features":    "payout,payin,quotes,ledger,limits,aml",
+		},
+	}
+}
+
+// walkFiles returns the files sc.walk produces, by slash-separated name.
+func walkFiles(t *testing.T, sc *scaffold) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := sc.walk(func(f *templateFile) error {
+		files[strings.ReplaceAll(f.rel, "\\", "/")] = string(f.data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestRenamePackage(t *testing.T) {
+	for _, tt := range renameTests {
+		files := walkFiles(t, testScaffold(tt.files))
+		if got := files[tt.file]; !strings.Contains(got, tt.want) {
+			t.Errorf("%s: %s =\n%s\nwant it to contain:\n%s", tt.name, tt.file, got, tt.want)
+		}
+		if got := files["tmpl.go"]; !strings.HasPrefix(got, "package payout\n") {
+			t.Errorf("%s: tmpl.go =\n%s\nwant package payout", tt.name, got)
+		}
+	}
+}
+
+// testKey is a fixed provider private key for tests.
+const testKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+var writeEnvTests = []struct {
+	name       string
+	oldEnv     map[string]string
+	newKey     bool   // pass testKey to writeEnv rather than nil
+	passphrase string // encrypt the key into a keystore
+	want       map[string]string
+	missing    []string // variables that must not be set
+}{
+	{
+		name:   "new",
+		newKey: true,
+		want: map[string]string{
+			privateKeyVar: testKey,
+			"PORT":        "8080",
+			"LOG_LEVEL":   "info",
+		},
+		missing: []string{keystorePathVar},
+	},
+	{
+		name:   "placeholder replaced, other variables kept",
+		oldEnv: map[string]string{privateKeyVar: envExamplePlaceholder, "PORT": "9090", "EXTRA": "x"},
+		newKey: true,
+		want: map[string]string{
+			privateKeyVar: testKey,
+			"PORT":        "9090",
+			"EXTRA":       "x",
+			"LOG_LEVEL":   "info",
+		},
+	},
+	{
+		name:   "configured key kept",
+		oldEnv: map[string]string{privateKeyVar: "0x01", "PROVIDER_PUBLIC_KEY": "0x02"},
+		want: map[string]string{
+			privateKeyVar:         "0x01",
+			"PROVIDER_PUBLIC_KEY": "0x02",
+			"PORT":                "8080",
+		},
+	},
+	{
+		name:       "keystore",
+		oldEnv:     map[string]string{privateKeyVar: envExamplePlaceholder, "LOG_LEVEL": "debug"},
+		newKey:     true,
+		passphrase: "secret",
+		want: map[string]string{
+			keystorePathVar: keystoreFile,
+			"LOG_LEVEL":     "debug",
+		},
+		missing: []string{privateKeyVar},
+	},
+}
+
+func TestWriteEnv(t *testing.T) {
+	example := []byte(privateKeyVar + "=" + envExamplePlaceholder + "\nPORT=3000\nLOG_LEVEL=info\n")
+	vars := map[string]string{"Port": "8080", "Endpoint": "https://api-sandbox.t-0.network"}
+	key, err := parsePrivateKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range writeEnvTests {
+		dir := t.TempDir()
+		var k *ecdsa.PrivateKey
+		if tt.newKey {
+			k = key
+		}
+		if err := writeEnv(dir, example, tt.oldEnv, vars, k, tt.passphrase); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		env, err := readEnv(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tt.want {
+			if env[k] != v {
+				t.Errorf("%s: %s = %q, want %q", tt.name, k, env[k], v)
+			}
+		}
+		for _, k := range tt.missing {
+			if v, ok := env[k]; ok {
+				t.Errorf("%s: %s = %q, want it unset", tt.name, k, v)
+			}
+		}
+		if tt.newKey && env["PROVIDER_PUBLIC_KEY"] != publicKeyHex(&key.PublicKey) {
+			t.Errorf("%s: PROVIDER_PUBLIC_KEY = %q, want the public key of the new key", tt.name, env["PROVIDER_PUBLIC_KEY"])
+		}
+	}
+}
+
+var packageUsesTests = []struct {
+	name  string
+	src   string // declarations after "package p; import "example.com/tmpl""
+	scope []string
+	uses  int // -1 if renaming is refused
+}{
+	{"uses", "var _ = tmpl.F\nfunc f() { tmpl.G(tmpl.H) }", nil, 3},
+	{"selected name", "var _ = tmpl.F\nvar _ = x.payout", nil, 1},
+	{"local shadowing qualifier", "func f(tmpl T) { tmpl.M() }\nvar _ = tmpl.F", nil, 1},
+	{"declared in file", "var _ = tmpl.F\nvar payout int", nil, -1},
+	{"used in file", "var _ = tmpl.F\nfunc f() { payout := 1; _ = payout }", nil, -1},
+	{"declared in package", "var _ = tmpl.F", []string{"payout"}, -1},
+	{"other import", "import payout \"example.com/other\"\nvar _ = tmpl.F\nvar _ = payout.X", nil, -1},
+}
+
+func TestPackageUses(t *testing.T) {
+	for _, tt := range packageUsesTests {
+		src := "package p\n\nimport \"example.com/tmpl\"\n\n" + tt.src + "\n"
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		scope := make(map[string]bool)
+		for _, name := range tt.scope {
+			scope[name] = true
+		}
+		uses, ok := packageUses(f, "tmpl", "payout", scope)
+		if n := len(uses); !ok && tt.uses != -1 || ok && n != tt.uses {
+			t.Errorf("%s: packageUses = %d uses, %v, want %d uses", tt.name, n, ok, tt.uses)
+		}
+	}
+}
+
+var loadTemplateTests = []struct {
+	name    string
+	goMod   string // contents of go.mod in the template directory; "-" for none
+	version string
+	mod     string
+	kind    errorKind // "" if loadTemplate succeeds
+}{
+	{"module", "module example.com/tmpl\n\ngo 1.25\n", "", "example.com/tmpl", ""},
+	{"with version", "module example.com/tmpl\n", "v1.0.0", "", errUsage},
+	{"no go.mod", "-", "", "", errUsage},
+	{"no module statement", "go 1.25\n", "", "", errParse},
+}
+
+func TestLoadTemplate(t *testing.T) {
+	for _, tt := range loadTemplateTests {
+		dir := t.TempDir()
+		if tt.goMod != "-" {
+			if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(tt.goMod), 0o666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		src, err := loadTemplate(dir, tt.version)
+		if tt.kind != "" {
+			var ke *kindError
+			if !errors.As(err, &ke) || ke.kind != tt.kind {
+				t.Errorf("%s: loadTemplate = %v, want %s error", tt.name, err, tt.kind)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: loadTemplate: %v", tt.name, err)
+			continue
+		}
+		if src.mod != tt.mod || src.dir != dir || src.version != "" || src.sum != "" {
+			t.Errorf("%s: loadTemplate = {mod %q, dir %q, version %q, sum %q}, want {mod %q, dir %q}", tt.name, src.mod, src.dir, src.version, src.sum, tt.mod, dir)
+		}
+		if _, err := fs.Stat(src.fsys, "go.mod"); err != nil {
+			t.Errorf("%s: template files: %v", tt.name, err)
+		}
+	}
+
+	// The template published alongside the tool loads from its
+	// directory in this repository as the default template.
+	src, err := loadTemplate("./template", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.mod != defaultTemplate() {
+		t.Errorf("loadTemplate(./template) module = %s, want %s", src.mod, defaultTemplate())
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	// Into a new directory.
+	parent := t.TempDir()
+	stdout, stderr, status := runMain(t, parent, "-offline", "-dry-run", "example.com/acme/payout")
+	if status != 0 {
+		t.Fatalf("-dry-run exited with status %d:\n%s", status, stderr)
+	}
+	for _, name := range []string{"go.mod", ".env", manifestFile} {
+		if want := filepath.Join("payout", name); !strings.Contains(stdout, want) {
+			t.Errorf("-dry-run output does not list %s:\n%s", want, stdout)
+		}
+	}
+	if de, err := os.ReadDir(parent); err != nil || len(de) > 0 {
+		t.Errorf("-dry-run created %v (err %v), want nothing", de, err)
+	}
+
+	// Over an existing file.
+	dir := t.TempDir()
+	readme := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(readme, []byte("mine\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, status := runMain(t, dir, "-offline", "-dry-run", "-force", "example.com/acme/payout", "."); status != 0 {
+		t.Fatalf("-dry-run -force exited with status %d:\n%s", status, stderr)
+	}
+	if de, err := os.ReadDir(dir); err != nil || len(de) != 1 {
+		t.Errorf("-dry-run -force left %v (err %v), want only README.md", de, err)
+	}
+	if data, err := os.ReadFile(readme); err != nil || string(data) != "mine\n" {
+		t.Errorf("-dry-run -force changed README.md to %q (err %v)", data, err)
+	}
+}
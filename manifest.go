@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+)
+
+// manifestFile is the name of the file recording how a module was scaffolded,
+// relative to the module root.
+const manifestFile = ".provider-starter.json"
+
+// A manifest records how a module was scaffolded from the template,
+// so that later versions of the template can be merged into it.
+type manifest struct {
+	Template   manifestTemplate  `json:"template"`
+	CLIVersion string            `json:"cliVersion"` // version of this tool
+	Module     string            `json:"module"`     // module path of the scaffolded module
+	Vars       map[string]string `json:"vars"`       // variables used to render .tmpl files
+	PublicKey  string            `json:"publicKey"`  // provider public key, hex-encoded
+
+	// ParentModule is the module path of the enclosing module
+	// that the template was scaffolded into as packages, if any.
+	// Module is then the import path of the root package, and the
+	// template's go.mod and go.sum files were merged into the parent's.
+	ParentModule string `json:"parentModule,omitempty"`
+
+	// Files maps the slash-separated name of each file generated from the
+	// template to the hex-encoded SHA-256 checksum of its contents.
+	// Files holding secrets, such as .env, are not listed, nor are existing
+	// files kept in place of the template version by -skip-existing or -merge.
+	Files map[string]string `json:"files"`
+}
+
+// A manifestTemplate identifies the template version a module was scaffolded from.
+type manifestTemplate struct {
+	Module  string `json:"module"`
+	Version string `json:"version,omitempty"` // empty for a local template directory
+	Sum     string `json:"sum,omitempty"`
+}
+
+// newManifest returns the manifest for a module scaffolded by sc from src,
+// for the provider key pair with the hex-encoded public key publicKey.
+// The caller adds the generated files with addFile.
+func newManifest(src *source, sc *scaffold, publicKey string) *manifest {
+	return &manifest{
+		Template:   manifestTemplate{Module: src.mod, Version: src.version, Sum: src.sum},
+		CLIVersion: cliVersion(),
+		Module:     sc.dstMod,
+		Vars:       sc.vars,
+		PublicKey:  publicKey,
+		Files:      make(map[string]string),
+	}
+}
+
+// addFile records the generated file name, relative to the module root,
+// with contents data.
+func (m *manifest) addFile(name string, data []byte) {
+	sum := sha256.Sum256(data)
+	m.Files[filepath.ToSlash(name)] = hex.EncodeToString(sum[:])
+}
+
+// addTemplateFile records the generated file f, streaming the contents
+// of a binary file. Symbolic links are not recorded.
+func (m *manifest) addTemplateFile(f *templateFile) error {
+	if f.link != "" {
+		return nil
+	}
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	m.Files[filepath.ToSlash(f.rel)] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// cliVersion returns the module version of this tool,
+// or "(devel)" if it was built from a local checkout.
+func cliVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+	return info.Main.Version
+}
+
+// readManifest reads the manifest of the module in dir.
+func readManifest(dir string) (*manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dir, manifestFile), err)
+	}
+	if m.Template.Module == "" || m.Module == "" {
+		return nil, fmt.Errorf("%s: missing template or module path", filepath.Join(dir, manifestFile))
+	}
+	return m, nil
+}
+
+// writeManifest writes m as the manifest of the module in dir.
+func writeManifest(dir string, m *manifest) error {
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestFile), append(data, '\n'), 0666)
+}
+
+// cmdVerify implements the verify command, which reports the files
+// generated from the template that were modified since scaffolding.
+func cmdVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest verify [dir]\n")
+		os.Exit(2)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	changed := 0
+	for _, name := range slices.Sorted(maps.Keys(m.Files)) {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("deleted  %s\n", name)
+			changed++
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != m.Files[name] {
+			fmt.Printf("modified %s\n", name)
+			changed++
+		}
+	}
+	if changed > 0 {
+		log.Printf("%d of %d files generated from %s changed since scaffolding", changed, len(m.Files), templateName(m.Template))
+		os.Exit(1)
+	}
+	log.Printf("all %d files generated from %s are unchanged", len(m.Files), templateName(m.Template))
+}
+
+// templateName returns a name for the template t for use in messages.
+func templateName(t manifestTemplate) string {
+	if t.Version == "" {
+		return t.Module
+	}
+	return t.Module + "@" + t.Version
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	m := &manifest{
+		Template: manifestTemplate{Module: "example.com/tmpl", Version: "v1.0.0"},
+		Module:   "example.com/acme/payout",
+		Files:    make(map[string]string),
+	}
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt"} {
+		data := []byte(name + "\n")
+		m.addFile(name, data)
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, data, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeManifest(dir, m); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, status := runMain(t, t.TempDir(), "verify", dir)
+	if status != 0 || stdout != "" {
+		t.Errorf("verify of an unchanged module: status %d, output:\n%s%s\nwant status 0, no output", status, stdout, stderr)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "sub", "c.txt")); err != nil {
+		t.Fatal(err)
+	}
+	want := "modified a.txt\ndeleted  sub/c.txt\n"
+	stdout, stderr, status = runMain(t, dir, "verify")
+	if status != 1 || stdout != want {
+		t.Errorf("verify of a changed module: status %d, output:\n%s%s\nwant status 1, output:\n%s", status, stdout, stderr, want)
+	}
+}
@@ -0,0 +1,77 @@
+//go:build ignore
+
+// Mkzip writes template.zip, the copy of the template module embedded in
+// the tool for scaffolding offline. The template module cannot be embedded
+// directly, because go:embed does not reach into other modules.
+//
+// Run it with go generate after changing the template.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	log.SetPrefix("mkzip: ")
+	log.SetFlags(0)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// WalkDir visits files in lexical order, and the headers carry no
+	// modification times or umask-dependent modes, so that the archive
+	// is reproducible.
+	err := filepath.WalkDir("template", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel("template", path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		var data []byte
+		var mode fs.FileMode
+		if d.Type()&fs.ModeSymlink != 0 {
+			// Store a symbolic link as a link, with its target as contents.
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			data, mode = []byte(filepath.ToSlash(target)), fs.ModeSymlink|0777
+		} else {
+			if data, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			// Record only the executable bit, as git does.
+			mode = 0644
+			if info.Mode()&0111 != 0 {
+				mode = 0755
+			}
+		}
+		h := &zip.FileHeader{Name: filepath.ToSlash(rel), Method: zip.Deflate}
+		h.SetMode(mode)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("template.zip", buf.Bytes(), 0666); err != nil {
+		log.Fatal(err)
+	}
+}
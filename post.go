@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A postStep is a step run in the new module after scaffolding it.
+type postStep struct {
+	name string
+	run  func(dir, msg string) ([]byte, error)
+}
+
+// postSteps are the steps that -post can select, in the order they run.
+// Git runs last, so that the initial commit holds the tidied go.mod and go.sum.
+var postSteps = []postStep{
+	{"tidy", func(dir, _ string) ([]byte, error) { return runIn(dir, "go", "mod", "tidy") }},
+	{"fmt", postFmt},
+	{"vet", func(dir, _ string) ([]byte, error) { return runIn(dir, "go", "vet", "./...") }},
+	{"build", func(dir, _ string) ([]byte, error) { return runIn(dir, "go", "build", "./...") }},
+	{"git", postGit},
+}
+
+// parsePost parses the -post list of step names, which may include "all",
+// and returns the selected steps in the order they run.
+func parsePost(list string) ([]postStep, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	var steps []postStep
+	for _, s := range postSteps {
+		if slices.Contains(names, s.name) || slices.Contains(names, "all") {
+			steps = append(steps, s)
+		}
+	}
+	for _, name := range names {
+		if name != "all" && !slices.ContainsFunc(postSteps, func(s postStep) bool { return s.name == name }) {
+			return nil, fmt.Errorf("unknown -post step %q; want tidy, fmt, vet, build, git or all", name)
+		}
+	}
+	return steps, nil
+}
+
+// A postResult is the outcome of running a postStep.
+type postResult struct {
+	name     string
+	err      error // nil if the step succeeded
+	output   []byte
+	duration time.Duration
+	skipped  bool // not run because an earlier step failed
+}
+
+// runPost runs steps in dir, stopping at the first failure,
+// and returns the result of every step.
+// Msg is the message of the initial commit made by the git step.
+func runPost(dir, msg string, steps []postStep) []postResult {
+	var results []postResult
+	failed := false
+	for _, s := range steps {
+		if failed {
+			results = append(results, postResult{name: s.name, skipped: true})
+			continue
+		}
+		start := time.Now()
+		out, err := s.run(dir, msg)
+		results = append(results, postResult{name: s.name, err: err, output: out, duration: time.Since(start)})
+		failed = err != nil
+	}
+	return results
+}
+
+// reportPost logs the results of runPost,
+// with the output of the step that failed, if any.
+// It reports whether all steps succeeded.
+func reportPost(results []postResult) bool {
+	ok := true
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			log.Printf("\t%s: skipped", r.name)
+		case r.err != nil:
+			ok = false
+			log.Printf("\t%s: FAILED: %v", r.name, r.err)
+			if out := strings.TrimRight(string(r.output), "\n"); out != "" {
+				for _, line := range strings.Split(out, "\n") {
+					log.Printf("\t\t%s", line)
+				}
+			}
+		default:
+			log.Printf("\t%s: ok (%v)", r.name, r.duration.Round(time.Millisecond))
+		}
+	}
+	return ok
+}
+
+// runIn runs the named command with args in dir,
+// returning its combined standard output and standard error.
+func runIn(dir, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("%s %s: %v", name, strings.Join(args, " "), err)
+	}
+	return out, err
+}
+
+// postFmt checks that the Go files in dir are gofmt-formatted.
+func postFmt(dir, _ string) ([]byte, error) {
+	out, err := runIn(dir, "gofmt", "-l", ".")
+	if err == nil && len(bytes.TrimSpace(out)) > 0 {
+		err = fmt.Errorf("gofmt -l: files are not formatted")
+	}
+	return out, err
+}
+
+// gitExcluded are the files kept out of the initial commit
+// and added to the repository's exclude list, because they hold secrets.
+var gitExcluded = []string{".env", keystoreFile}
+
+// postGit makes dir a new git repository and commits the new module
+// to it, except for the gitExcluded files. It refuses to run if dir is
+// already inside a git repository, rather than commit to that one.
+func postGit(dir, msg string) ([]byte, error) {
+	if out, err := runIn(dir, "git", "rev-parse", "--show-toplevel"); err == nil {
+		return nil, fmt.Errorf("%s is already in git repository %s", dir, strings.TrimSpace(string(out)))
+	}
+	var output []byte
+	run := func(args ...string) error {
+		out, err := runIn(dir, "git", args...)
+		output = append(output, out...)
+		return err
+	}
+	if err := run("init", "-q"); err != nil {
+		return output, err
+	}
+	exclude := filepath.Join(dir, ".git", "info", "exclude")
+	if err := os.MkdirAll(filepath.Dir(exclude), 0777); err != nil {
+		return output, err
+	}
+	f, err := os.OpenFile(exclude, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return output, err
+	}
+	for _, name := range gitExcluded {
+		fmt.Fprintf(f, "/%s\n", name)
+	}
+	if err := f.Close(); err != nil {
+		return output, err
+	}
+	if err := run("add", "-A"); err != nil {
+		return output, err
+	}
+	err = run("commit", "-q", "-m", msg)
+	return output, err
+}
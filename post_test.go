@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var parsePostTests = []struct {
+	list string
+	want string // names of the selected steps, comma-separated
+	err  bool
+}{
+	{"", "", false},
+	{"tidy", "tidy", false},
+	{"git, tidy", "tidy,git", false},
+	{"build,fmt,,vet", "fmt,vet,build", false},
+	{"tidy,tidy", "tidy", false},
+	{"all", "tidy,fmt,vet,build,git", false},
+	{"git,all", "tidy,fmt,vet,build,git", false},
+	{"lint", "", true},
+	{"tidy,Git", "", true},
+}
+
+func TestParsePost(t *testing.T) {
+	for _, tt := range parsePostTests {
+		steps, err := parsePost(tt.list)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parsePost(%q) succeeded, want error", tt.list)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePost(%q): %v", tt.list, err)
+			continue
+		}
+		var names []string
+		for _, s := range steps {
+			names = append(names, s.name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("parsePost(%q) = %s, want %s", tt.list, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+)
+
+// An errorKind classifies the errors that end the scaffolder.
+// Each kind has its own exit status.
+type errorKind string
+
+const (
+	errOther    errorKind = "error"   // exit status 1
+	errUsage    errorKind = "usage"   // exit status 2: invalid arguments or flags
+	errNetwork  errorKind = "network" // exit status 3: downloading the template failed
+	errTarget   errorKind = "target"  // exit status 4: the target directory cannot be scaffolded into
+	errParse    errorKind = "parse"   // exit status 5: a template, .env or go.mod file cannot be parsed
+	errKey      errorKind = "key"     // exit status 6: generating, loading or storing the provider key failed
+	errPostStep errorKind = "post"    // exit status 1: a -post step failed
+)
+
+// exitCode returns the exit status for errors of kind k.
+func (k errorKind) exitCode() int {
+	switch k {
+	case errUsage:
+		return 2
+	case errNetwork:
+		return 3
+	case errTarget:
+		return 4
+	case errParse:
+		return 5
+	case errKey:
+		return 6
+	}
+	return 1
+}
+
+// A kindError is an error of a known kind.
+type kindError struct {
+	kind errorKind
+	err  error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+func (e *kindError) Unwrap() error { return e.err }
+
+// withKind returns err classified as kind, or nil if err is nil.
+func withKind(kind errorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind, err}
+}
+
+// A result is the outcome of scaffolding, printed by -json.
+type result struct {
+	Dir       string           `json:"dir"`
+	Module    string           `json:"module"`
+	PublicKey string           `json:"publicKey,omitempty"`
+	Template  manifestTemplate `json:"template"`
+	Files     []fileResult     `json:"files"`
+	Rewrites  []rewriteResult  `json:"rewrites"`
+	Warnings  []string         `json:"warnings"`
+	Post      []postStatus     `json:"post,omitempty"`
+	Error     *errorResult     `json:"error,omitempty"`
+}
+
+// A fileResult reports what was done with a file in the new module.
+type fileResult struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // "written", or a placeFile action
+}
+
+// A rewriteResult reports a reference to the template module path, outside
+// an import path, that was rewritten to the new module path.
+type rewriteResult struct {
+	File string `json:"file"` // slash-separated name, relative to the module root
+	Line int    `json:"line"` // 1-based line number in the template file
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// A postStatus reports the outcome of a -post step.
+type postStatus struct {
+	Step     string `json:"step"`
+	Status   string `json:"status"` // "ok", "failed" or "skipped"
+	Error    string `json:"error,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Duration int64  `json:"durationMs"`
+}
+
+// An errorResult is the error that ended the scaffolder, printed by -json.
+type errorResult struct {
+	Kind     errorKind `json:"kind"`
+	Message  string    `json:"message"`
+	ExitCode int       `json:"exitCode"`
+}
+
+// addFile records the action taken for the file named rel.
+func (r *result) addFile(rel, action string) {
+	if action == "" {
+		action = "written"
+	}
+	r.Files = append(r.Files, fileResult{rel, action})
+}
+
+// addPost records the results of the -post steps.
+func (r *result) addPost(results []postResult) {
+	for _, pr := range results {
+		s := postStatus{Step: pr.name, Status: "ok", Output: string(pr.output), Duration: pr.duration.Milliseconds()}
+		switch {
+		case pr.skipped:
+			s.Status = "skipped"
+		case pr.err != nil:
+			s.Status = "failed"
+			s.Error = pr.err.Error()
+		}
+		r.Post = append(r.Post, s)
+	}
+}
+
+// printJSON prints v as indented JSON to standard output.
+func printJSON(v any) {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// fatal reports err and exits with the status for its kind,
+// which is kind unless err is a kindError.
+// With -json, the error is printed to standard output as a JSON object
+// holding an errorResult under "error".
+func fatal(kind errorKind, err error) {
+	var ke *kindError
+	if errors.As(err, &ke) {
+		kind = ke.kind
+	}
+	if *jsonFlag {
+		printJSON(errorOutput{newErrorResult(kind, err)})
+	} else {
+		log.Print(err)
+	}
+	os.Exit(kind.exitCode())
+}
+
+// fatalf is like fatal with an error formatted from format and args.
+func fatalf(kind errorKind, format string, args ...any) {
+	fatal(kind, fmt.Errorf(format, args...))
+}
+
+// An errorOutput is the JSON object printed by -json for an error.
+type errorOutput struct {
+	Error *errorResult `json:"error"`
+}
+
+func newErrorResult(kind errorKind, err error) *errorResult {
+	return &errorResult{Kind: kind, Message: err.Error(), ExitCode: kind.exitCode()}
+}
+
+// walkErrorKind returns the kind of an error from scaffold.walk:
+// errOther for a file system error, and errParse for an error
+// rendering or rewriting a template file. Errors of a known kind,
+// such as those from placeFile for an existing file in the way,
+// keep their kind in fatal.
+func walkErrorKind(err error) errorKind {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return errOther
+	}
+	return errParse
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var exitCodeTests = []struct {
+	kind errorKind
+	code int
+}{
+	{errOther, 1},
+	{errUsage, 2},
+	{errNetwork, 3},
+	{errTarget, 4},
+	{errParse, 5},
+	{errKey, 6},
+	{errPostStep, 1},
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tt := range exitCodeTests {
+		if code := tt.kind.exitCode(); code != tt.code {
+			t.Errorf("%s.exitCode() = %d, want %d", tt.kind, code, tt.code)
+		}
+	}
+}
+
+func TestWithKind(t *testing.T) {
+	if err := withKind(errUsage, nil); err != nil {
+		t.Errorf("withKind(errUsage, nil) = %v, want nil", err)
+	}
+
+	// The kind survives further wrapping, and the cause stays visible.
+	err := fmt.Errorf("loading template: %w", withKind(errParse, fs.ErrNotExist))
+	var ke *kindError
+	if !errors.As(err, &ke) || ke.kind != errParse {
+		t.Errorf("errors.As(%v) found no errParse kindError", err)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is(%v, fs.ErrNotExist) = false, want true", err)
+	}
+}
+
+var exitStatusTests = []struct {
+	name   string
+	args   []string // run in a directory holding a non-empty directory full
+	status int
+}{
+	{"usage", []string{"-offline", "-payment-method", "FOO", "example.com/acme/payout"}, 2},
+	{"target", []string{"-offline", "example.com/acme/payout", "full"}, 4},
+	{"key", []string{"-offline", "-import-key", "missing.env", "example.com/acme/payout"}, 6},
+}
+
+func TestExitStatus(t *testing.T) {
+	for _, tt := range exitStatusTests {
+		dir := t.TempDir()
+		if err := os.Mkdir(filepath.Join(dir, "full"), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "full", "a.txt"), nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+		_, stderr, status := runMain(t, dir, tt.args...)
+		if status != tt.status {
+			t.Errorf("%s: exit status %d, want %d; stderr:\n%s", tt.name, status, tt.status, stderr)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/t-0-network/provider-starter-go/internal/edit"
+)
+
+// A replacement records a reference to the template module path,
+// outside of import declarations, that was rewritten to the new module path.
+type replacement struct {
+	file string // file name, relative to the module root
+	line int    // 1-based line number in the template file
+	old  string // text that was replaced
+	new  string // replacement text
+}
+
+func (r replacement) String() string {
+	return fmt.Sprintf("%s:%d: %s -> %s", r.file, r.line, r.old, r.new)
+}
+
+// fixModPath queues edits in buf rewriting every reference to srcMod,
+// or to a package path within it, in data[start:end] to refer to dstMod,
+// and returns the replacements made.
+// A reference must not be part of a longer path or identifier:
+// the text "example.com/mod" matches in "example.com/mod/internal"
+// and "example.com/mod.Version", but not in "example.com/modern".
+func fixModPath(buf *edit.Buffer, data []byte, start, end int, file, srcMod, dstMod string) ([]replacement, error) {
+	var reps []replacement
+	for i := start; i < end; {
+		j := bytes.Index(data[i:end], []byte(srcMod))
+		if j < 0 {
+			break
+		}
+		pos := i + j
+		i = pos + len(srcMod)
+		if pos > 0 && isPathByte(data[pos-1]) {
+			continue
+		}
+		if i < len(data) && isPathByte(data[i]) && data[i] != '/' && data[i] != '.' {
+			continue
+		}
+		if err := buf.TryReplace(pos, i, dstMod); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		reps = append(reps, replacement{
+			file: file,
+			line: 1 + bytes.Count(data[:pos], []byte("\n")),
+			old:  srcMod,
+			new:  dstMod,
+		})
+	}
+	return reps, nil
+}
+
+// isPathByte reports whether c can appear in a module path.
+func isPathByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		strings.IndexByte("-._~/", c) >= 0
+}
+
+// isText reports whether data looks like text rather than binary data:
+// it must be valid UTF-8 without NUL bytes.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// fixText rewrites the references to srcMod in a non-Go text file,
+// such as a Dockerfile, Makefile or YAML configuration, to refer to dstMod.
+func fixText(data []byte, file string, srcMod, dstMod string) ([]byte, []replacement, error) {
+	if !isText(data) {
+		return data, nil, nil
+	}
+	buf := edit.NewBuffer(data)
+	reps, err := fixModPath(buf, data, 0, len(data), file, srcMod, dstMod)
+	if err != nil {
+		return nil, nil, err
+	}
+	new, err := buf.Apply()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return new, reps, nil
+}
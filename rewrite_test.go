@@ -0,0 +1,57 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/t-0-network/provider-starter-go/internal/edit"
+)
+
+var fixModPathTests = []struct {
+	name  string
+	data  string
+	want  string
+	lines []int // lines of the replacements
+}{
+	{
+		name:  "module path",
+		data:  "FROM golang\nRUN go install example.com/tmpl\n",
+		want:  "FROM golang\nRUN go install example.com/acme/payout\n",
+		lines: []int{2},
+	},
+	{
+		name:  "package path and selector",
+		data:  "example.com/tmpl/internal/x\nexample.com/tmpl.Version\n",
+		want:  "example.com/acme/payout/internal/x\nexample.com/acme/payout.Version\n",
+		lines: []int{1, 2},
+	},
+	{
+		name: "longer path",
+		data: "example.com/tmplx example.com/tmpl-2 sub.example.com/tmpl\n",
+		want: "example.com/tmplx example.com/tmpl-2 sub.example.com/tmpl\n",
+	},
+	{
+		name:  "at start and end",
+		data:  "example.com/tmpl",
+		want:  "example.com/acme/payout",
+		lines: []int{1},
+	},
+}
+
+func TestFixModPath(t *testing.T) {
+	for _, tt := range fixModPathTests {
+		buf := edit.NewBuffer([]byte(tt.data))
+		reps, err := fixModPath(buf, []byte(tt.data), 0, len(tt.data), "file", "example.com/tmpl", "example.com/acme/payout")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var lines []int
+		for _, r := range reps {
+			lines = append(lines, r.line)
+		}
+		if got := buf.String(); got != tt.want || !slices.Equal(lines, tt.lines) {
+			t.Errorf("%s: fixModPath = %q at lines %v, want %q at lines %v", tt.name, got, lines, tt.want, tt.lines)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"golang.org/x/mod/module"
+)
+
+// A scaffold describes how to turn the template into a new module.
+type scaffold struct {
+	srcMod string // module path of the template
+	fsys   fs.FS  // the template files
+	dstMod string // module path of the new module
+
+	vars map[string]string // variables for rendering .tmpl files
+
+	scopes map[string]map[string]bool // package-level names by template directory; see scope
+}
+
+// A templateFile is a file of the template along with the contents
+// to write for it in the new module.
+//
+// The contents of a binary file are not loaded into memory: orig and
+// data are nil, and open streams the file from the template instead.
+// A symbolic link has no contents, only a target.
+type templateFile struct {
+	src  string      // path of the template file, relative to the template root
+	rel  string      // path to write the file to, relative to the new module root
+	orig []byte      // contents in the template
+	data []byte      // contents after rendering and rewriting for the new module
+	mode fs.FileMode // permissions to create the file with: 0777 for an executable, 0666 otherwise
+	link string      // slash-separated target of a symbolic link, or ""
+
+	binary bool  // copied unchanged from fsys without loading it
+	fsys   fs.FS // the template files, for a binary file
+
+	reps []replacement // references to the template module rewritten outside imports
+}
+
+var currencyRE = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// paymentMethods are the payment methods -payment-method accepts: the names
+// of the common.PaymentMethodType constants of the provider SDK the template
+// requires, without their PAYMENT_METHOD_TYPE_ prefix, except UNSPECIFIED.
+var paymentMethods = []string{
+	"SEPA", "SWIFT", "ACH", "WIRE", "FPS", "M_PESA", "G_CASH",
+	"INDIAN_BANK_TRANSFER", "PESONET", "INSTAPAY", "PAKISTAN_BANK_TRANSFER",
+	"PAKISTAN_MOBILE_WALLET", "PIX", "AFRICAN_MOBILE_MONEY", "CNAPS", "NIP",
+}
+
+// The t-0 Network API endpoints selected by the -endpoint names.
+var endpoints = map[string]string{
+	"sandbox":    "https://api-sandbox.t-0.network",
+	"production": "https://api.t-0.network",
+}
+
+// templateVars returns the variables available to .tmpl files
+// when scaffolding the module dstMod, set from the command-line flags:
+//
+//	ProjectName     last element of the module path, without a major version suffix
+//	ModulePath      module path of the new module
+//	PackageName     name of the root package of the new module
+//	Port            default port the service listens on
+//	Endpoint        URL of the t-0 Network API
+//	PayOutCurrency  currency of the published quotes, such as EUR
+//	PaymentMethod   payment method of the published quotes, such as SEPA;
+//	                the suffix of a common.PaymentMethodType constant
+//	Features        comma-separated features included, such as payout,quotes
+//
+// and, when set by the go.mod flags, the settings fixGoMod applies to the
+// new go.mod file, with dir being the target directory (see goModVars):
+//
+//	GoVersion       version for the go line
+//	Toolchain       name for the toolchain line, or none to remove it
+//	SDKVersion      version of the provider SDK to require
+//	Replace         replace directives to add, one old[@v]=new[@v] per line
+func templateVars(dstMod, dir string) (map[string]string, error) {
+	pkg := *packageNameFlag
+	if pkg == "" {
+		pkg = packageName(dstMod)
+	} else if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("invalid -package-name: %q is not a Go identifier", pkg)
+	}
+	if err := checkPort(*portFlag); err != nil {
+		return nil, fmt.Errorf("invalid -port: %v", err)
+	}
+	if err := checkEndpoint(*endpointFlag); err != nil {
+		return nil, fmt.Errorf("invalid -endpoint: %v", err)
+	}
+	if err := checkCurrency(*currencyFlag); err != nil {
+		return nil, fmt.Errorf("invalid -currency: %v", err)
+	}
+	if err := checkPaymentMethod(*paymentMethodFlag); err != nil {
+		return nil, fmt.Errorf("invalid -payment-method: %v", err)
+	}
+	feats, err := parseFeatures(*featuresFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -features: %v", err)
+	}
+	endpoint, ok := endpoints[*endpointFlag]
+	if !ok {
+		endpoint = *endpointFlag
+	}
+	modVars, err := goModVars(dir)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]string{
+		"ProjectName":    projectName(dstMod),
+		"ModulePath":     dstMod,
+		"PackageName":    pkg,
+		"Port":           *portFlag,
+		"Endpoint":       endpoint,
+		"PayOutCurrency": strings.ToUpper(*currencyFlag),
+		"PaymentMethod":  strings.ToUpper(*paymentMethodFlag),
+		"Features":       feats,
+	}
+	maps.Copy(vars, modVars)
+	return vars, nil
+}
+
+// checkModulePath reports whether p is a valid path for the new module.
+// Besides the paths accepted by module.CheckPath, it accepts paths whose
+// first element is not a domain name, like go mod init does,
+// for modules that are never downloaded by path.
+func checkModulePath(p string) error {
+	err := module.CheckPath(p)
+	if err != nil {
+		first, _, _ := strings.Cut(p, "/")
+		if !strings.Contains(first, ".") && module.CheckImportPath(p) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// suggestModulePath returns a valid module path resembling p,
+// or "" if it finds none: it strips any URL scheme and .git suffix,
+// lower-cases p, and replaces runs of other characters than letters,
+// digits and ._~/- with a single hyphen.
+func suggestModulePath(p string) string {
+	p = strings.TrimSpace(p)
+	if _, rest, ok := strings.Cut(p, "://"); ok {
+		p = rest
+	}
+	p = strings.TrimSuffix(strings.TrimSuffix(p, "/"), ".git")
+	p = strings.ToLower(p)
+	var elems []string
+	for _, elem := range strings.Split(p, "/") {
+		elem = strings.Trim(invalidPathRE.ReplaceAllString(elem, "-"), "-.")
+		if elem != "" {
+			elems = append(elems, elem)
+		}
+	}
+	p = strings.Join(elems, "/")
+	if p == "" || checkModulePath(p) != nil {
+		return ""
+	}
+	return p
+}
+
+var invalidPathRE = regexp.MustCompile(`[^a-z0-9._~-]+`)
+
+// projectName returns the last element of the module path mod,
+// without a major version suffix such as /v2.
+func projectName(mod string) string {
+	if prefix, _, ok := module.SplitPathVersion(mod); ok && prefix != "" {
+		mod = prefix
+	}
+	return path.Base(mod)
+}
+
+// packageName returns the default name of the root package of the module
+// mod: its projectName, lower-cased, without the characters not allowed
+// in identifiers, and prefixed with "x" if it would not be an identifier.
+func packageName(mod string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', r == '_':
+			return r
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, projectName(mod))
+	if !token.IsIdentifier(name) {
+		name = "x" + name
+	}
+	return name
+}
+
+// pkgName returns the name of the root package of the new module.
+func (sc *scaffold) pkgName() string {
+	return sc.vars["PackageName"]
+}
+
+func checkPort(s string) error {
+	if n, err := strconv.Atoi(s); err != nil || n <= 0 || n > 65535 {
+		return fmt.Errorf("%q is not a port number", s)
+	}
+	return nil
+}
+
+func checkEndpoint(s string) error {
+	if _, ok := endpoints[s]; ok {
+		return nil
+	}
+	if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not sandbox, production, or an http(s) URL", s)
+	}
+	return nil
+}
+
+func checkCurrency(s string) error {
+	if !currencyRE.MatchString(strings.ToUpper(s)) {
+		return fmt.Errorf("%q is not a three-letter currency code", s)
+	}
+	return nil
+}
+
+func checkPaymentMethod(s string) error {
+	if !slices.Contains(paymentMethods, strings.ToUpper(s)) {
+		return fmt.Errorf("%q is not a payment method; use one of %s", s, strings.Join(paymentMethods, ", "))
+	}
+	return nil
+}
+
+// walk calls fn for each file in the template, with its contents
+// rendered and rewritten for the new module.
+//
+// A file with a .tmpl suffix is rendered as a text/template using sc.vars,
+// and written without the suffix. It takes the place of a file with the same
+// name without the suffix, which lets a template keep a working default
+// version of the file next to the rendered one.
+//
+// Files and regions of files belonging to features left out of the
+// new module are removed, as described at selectFeatures.
+//
+// Binary files, and files listed in the template's .starter-verbatim file
+// (see readVerbatim), are neither rendered nor rewritten. Symbolic links
+// are passed to fn as links, never followed.
+func (sc *scaffold) walk(fn func(f *templateFile) error) error {
+	verbatim, err := readVerbatim(sc.fsys)
+	if err != nil {
+		return err
+	}
+	return fs.WalkDir(sc.fsys, ".", func(src string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || src == verbatimFile {
+			return nil
+		}
+		if _, err := fs.Stat(sc.fsys, src+".tmpl"); err == nil {
+			return nil
+		}
+		rel := filepath.FromSlash(src)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := readLink(sc.fsys, src)
+			if err != nil {
+				return err
+			}
+			return fn(&templateFile{src: rel, rel: rel, link: target})
+		case !d.Type().IsRegular():
+			return fmt.Errorf("%s: unsupported file type %v", src, d.Type())
+		}
+
+		f := &templateFile{src: rel, rel: rel, mode: 0666}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		head, err := readHead(sc.fsys, src)
+		if err != nil {
+			return err
+		}
+		// Module zip files do not record permissions, so a script
+		// is made executable by its #! line too.
+		if info.Mode()&0111 != 0 || bytes.HasPrefix(head, []byte("#!")) {
+			f.mode = 0777
+		}
+		if !strings.HasSuffix(src, ".tmpl") && !isTextHead(head) {
+			f.binary, f.fsys = true, sc.fsys
+			return fn(f)
+		}
+
+		orig, err := fs.ReadFile(sc.fsys, src)
+		if err != nil {
+			return err
+		}
+		f.orig, f.data = orig, orig
+		if verbatim.match(src) {
+			return fn(f)
+		}
+		if name, ok := strings.CutSuffix(rel, ".tmpl"); ok {
+			f.rel = name
+			if f.data, err = sc.render(rel, orig); err != nil {
+				return err
+			}
+		}
+
+		var keep bool
+		if f.data, keep, err = selectFeatures(f.data, f.rel, sc.hasFeature); err != nil || !keep {
+			return err
+		}
+
+		isRoot := !strings.Contains(f.rel, string(filepath.Separator))
+		switch {
+		case strings.HasSuffix(f.rel, ".go"):
+			var scope map[string]bool
+			if scope, err = sc.scope(path.Dir(src)); err != nil {
+				return err
+			}
+			f.data, f.reps, err = fixGo(f.data, f.rel, sc.srcMod, sc.dstMod, sc.pkgName(), isRoot, scope)
+		case f.rel == "go.mod":
+			f.data, f.reps, err = fixGoMod(f.data, sc.srcMod, sc.dstMod, sc.vars)
+		case f.rel == "go.sum":
+			// Checksums refer to dependencies, never to the template itself.
+		default:
+			f.data, f.reps, err = fixText(f.data, f.rel, sc.srcMod, sc.dstMod)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(f)
+	})
+}
+
+// scope returns the package-level names declared in the Go files,
+// including .go.tmpl files, in the template directory dir.
+func (sc *scaffold) scope(dir string) (map[string]bool, error) {
+	if names, ok := sc.scopes[dir]; ok {
+		return names, nil
+	}
+	entries, err := fs.ReadDir(sc.fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		if !e.Type().IsRegular() || !strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, ".go.tmpl") {
+			continue
+		}
+		data, err := fs.ReadFile(sc.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(name, ".tmpl") {
+			if data, err = sc.render(filepath.FromSlash(name), data); err != nil {
+				return nil, err
+			}
+		}
+		f, err := parser.ParseFile(fset, name, data, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("parsing source module:\n%s", err)
+		}
+		packageNames(f, names)
+	}
+	if sc.scopes == nil {
+		sc.scopes = make(map[string]map[string]bool)
+	}
+	sc.scopes[dir] = names
+	return names, nil
+}
+
+// render executes the template file named file, with contents data,
+// using the variables in sc.vars.
+func (sc *scaffold) render(file string, data []byte) ([]byte, error) {
+	t, err := template.New(file).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, sc.vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// An existsPolicy says what to do with a template file that already exists,
+// with different contents, in the target directory.
+type existsPolicy int
+
+const (
+	failExisting      existsPolicy = iota // stop scaffolding
+	overwriteExisting                     // replace the existing file
+	skipExisting                          // keep the existing file
+	mergeExisting                         // keep the existing file and write file.new
+)
+
+// existingPolicy returns the policy selected by the -force, -skip-existing
+// and -merge flags.
+func existingPolicy() (existsPolicy, error) {
+	policy := failExisting
+	n := 0
+	if *forceFlag {
+		policy, n = overwriteExisting, n+1
+	}
+	if *skipExistingFlag {
+		policy, n = skipExisting, n+1
+	}
+	if *mergeFlag {
+		policy, n = mergeExisting, n+1
+	}
+	if n > 1 {
+		return 0, fmt.Errorf("at most one of -force, -skip-existing and -merge may be given")
+	}
+	return policy, nil
+}
+
+// placeFile writes f to the file dst, applying policy if dst already exists
+// with different contents, and returns a description of what it did with an
+// existing file, or "" if there was none. If dryRun is set, placeFile only
+// returns the description, without writing anything.
+func placeFile(dst string, f *templateFile, policy existsPolicy, dryRun bool) (action string, err error) {
+	same, err := sameFile(dst, f)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// New file.
+	case err != nil:
+		return "", err
+	case same:
+		return "exists, unchanged", nil
+	case policy == overwriteExisting:
+		action = "exists, overwritten"
+	case policy == skipExisting:
+		return "exists, skipped", nil
+	case policy == mergeExisting:
+		dst += ".new"
+		action = "exists, template version written to " + filepath.Base(dst)
+	default:
+		return "", withKind(errTarget, fmt.Errorf("%s already exists", dst))
+	}
+	if dryRun {
+		return action, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+		return "", err
+	}
+	return action, writeFile(dst, f)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var suggestModulePathTests = []struct {
+	in, want string
+}{
+	{"My Project", "my-project"},
+	{"https://github.com/Acme/Pay_Out.git", "github.com/acme/pay_out"},
+	{"github.com/acme/pay out/", "github.com/acme/pay-out"},
+	{"  acme//payout ", "acme/payout"},
+	{"-.-", ""},
+	{"", ""},
+}
+
+func TestSuggestModulePath(t *testing.T) {
+	for _, tt := range suggestModulePathTests {
+		if got := suggestModulePath(tt.in); got != tt.want {
+			t.Errorf("suggestModulePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+var packageNameTests = []struct {
+	mod, want string
+}{
+	{"github.com/acme/payout", "payout"},
+	{"github.com/acme/pay-out", "payout"},
+	{"github.com/acme/Pay.Out/v2", "payout"},
+	{"github.com/acme/pay_out", "pay_out"},
+	{"github.com/acme/2fa", "x2fa"},
+	{"github.com/acme/go", "xgo"},
+}
+
+func TestPackageName(t *testing.T) {
+	for _, tt := range packageNameTests {
+		if got := packageName(tt.mod); got != tt.want {
+			t.Errorf("packageName(%q) = %q, want %q", tt.mod, got, tt.want)
+		}
+	}
+}
+
+var paymentMethodTests = []struct {
+	method string
+	ok     bool
+}{
+	{"SEPA", true},
+	{"pix", true},
+	{"M_PESA", true},
+	{"FOO", false},
+	{"UNSPECIFIED", false},
+	{"PAYMENT_METHOD_TYPE_SEPA", false},
+}
+
+func TestPaymentMethod(t *testing.T) {
+	defer func(method string) { *paymentMethodFlag = method }(*paymentMethodFlag)
+	src, err := embeddedTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range paymentMethodTests {
+		*paymentMethodFlag = tt.method
+		vars, err := templateVars("example.com/acme/corridor", t.TempDir())
+		if !tt.ok {
+			if err == nil || !strings.Contains(err.Error(), "SEPA, SWIFT") {
+				t.Errorf("-payment-method %s: templateVars error %v, want one listing the payment methods", tt.method, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("-payment-method %s: %v", tt.method, err)
+			continue
+		}
+		files := walkFiles(t, &scaffold{srcMod: src.mod, fsys: src.fsys, dstMod: "example.com/acme/corridor", vars: vars})
+		want := "common.PaymentMethodType_PAYMENT_METHOD_TYPE_" + strings.ToUpper(tt.method) + "\n"
+		if got := files["internal/corridor.go"]; !strings.Contains(got, want) {
+			t.Errorf("-payment-method %s: internal/corridor.go =\n%s\nwant it to contain %s", tt.method, got, want)
+		}
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/joho/godotenv"
 	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
 	"github.com/t-0-network/provider-sdk-go/network"
@@ -34,17 +37,20 @@ func main() {
 
 	// TODO: Step 1.2 Share the generated public key from .env with t-0 team
 
-	// TODO: Step 1.3 Replace publishQuotes with your own quote publishing logic
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// starter:begin payout payin
+	// TODO: Step 1.3 Replace publishQuotes with your own quote publishing logic
 	go internal.PublishQuotes(ctx, networkClient)
+	// starter:end
 
+	// starter:begin quotes
 	// TODO: Step 1.4 Verify that quotes for target currency are successfully received
 	go internal.GetQuote(ctx, networkClient)
+	// starter:end
 
-	waitForShutdownSignal(cancel, shutdownFunc)
+	waitForShutdownSignal(ctx, cancel, shutdownFunc)
 
 	// TODO: Step 2.2 Deploy your integration and provide t-0 team with the base URL
 	// TODO: Step 2.3 Test payment submission
@@ -56,14 +62,33 @@ func loadConfig() Config {
 		log.Fatalf("Failed to load .env file: %v", err)
 	}
 
+	privateKey := os.Getenv("PROVIDER_PRIVATE_KEY")
+	if path := os.Getenv("PROVIDER_KEYSTORE_PATH"); path != "" {
+		privateKey = loadKeystore(path, os.Getenv("PROVIDER_KEYSTORE_PASSPHRASE"))
+	}
+
 	return Config{
 		NetworkPublicKey:   provider.NetworkPublicKeyHexed(os.Getenv("NETWORK_PUBLIC_KEY")),
-		ProviderPrivateKey: network.PrivateKeyHexed(os.Getenv("PROVIDER_PRIVATE_KEY")),
+		ProviderPrivateKey: network.PrivateKeyHexed(privateKey),
 		TZeroEndpoint:      os.Getenv("TZERO_ENDPOINT"),
 		ServerAddr:         ":" + os.Getenv("PORT"),
 	}
 }
 
+// loadKeystore decrypts the private key in the encrypted JSON keystore file at path
+// and returns it hex-encoded.
+func loadKeystore(path, passphrase string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read keystore: %v", err)
+	}
+	key, err := keystore.DecryptKey(data, passphrase)
+	if err != nil {
+		log.Fatalf("Failed to decrypt keystore %s: %v", path, err)
+	}
+	return "0x" + hex.EncodeToString(crypto.FromECDSA(key.PrivateKey))
+}
+
 func initNetworkClient(config Config) paymentconnect.NetworkServiceClient {
 	networkClient, err := network.NewServiceClient(
 		config.ProviderPrivateKey,
@@ -103,8 +128,8 @@ func startProviderServer(config Config, networkClient paymentconnect.NetworkServ
 	}
 }
 
-func waitForShutdownSignal(cancel context.CancelFunc, shutdownFunc func()) {
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+func waitForShutdownSignal(ctx context.Context, cancel context.CancelFunc, shutdownFunc func()) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	<-ctx.Done()
@@ -0,0 +1,11 @@
+// starter:feature payout payin
+
+package internal
+
+import "github.com/t-0-network/provider-sdk-go/api/tzero/v1/common"
+
+// The currency and payment method of the quotes published by PublishQuotes.
+const (
+	quoteCurrency      = "EUR"
+	quotePaymentMethod = common.PaymentMethodType_PAYMENT_METHOD_TYPE_SEPA
+)
@@ -1,3 +1,5 @@
+// starter:feature quotes
+
 package internal
 
 import (
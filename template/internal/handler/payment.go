@@ -9,7 +9,11 @@ import (
 	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
 )
 
+// ProviderServiceImplementation handles the requests of the t-0 Network.
+// Requests to methods it does not implement fail with connect.CodeUnimplemented.
 type ProviderServiceImplementation struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+
 	networkClient paymentconnect.NetworkServiceClient
 }
 
@@ -25,6 +29,7 @@ func NewProviderServiceImplementation(networkClient paymentconnect.NetworkServic
 
 var _ paymentconnect.ProviderServiceHandler = (*ProviderServiceImplementation)(nil)
 
+// starter:begin payin
 // TODO: Step 2.1 implement how you handle updates of payment initiated by you
 func (s *ProviderServiceImplementation) UpdatePayment(
 	ctx context.Context, req *connect.Request[payment.UpdatePaymentRequest],
@@ -32,6 +37,9 @@ func (s *ProviderServiceImplementation) UpdatePayment(
 	return connect.NewResponse(&payment.UpdatePaymentResponse{}), nil
 }
 
+// starter:end
+
+// starter:begin payout
 // TODO: Step 2.4 implement how you do payouts (payments initiated by your counterparts)
 func (s *ProviderServiceImplementation) PayOut(ctx context.Context, req *connect.Request[payment.PayoutRequest],
 ) (*connect.Response[payment.PayoutResponse], error) {
@@ -56,6 +64,13 @@ func (s *ProviderServiceImplementation) PayOut(ctx context.Context, req *connect
 	return connect.NewResponse(&payment.PayoutResponse{}), nil
 }
 
+func ref(s string) *string {
+	return &s
+}
+
+// starter:end
+
+// starter:begin limits
 func (s *ProviderServiceImplementation) UpdateLimit(
 	ctx context.Context, req *connect.Request[payment.UpdateLimitRequest],
 ) (*connect.Response[payment.UpdateLimitResponse], error) {
@@ -63,6 +78,9 @@ func (s *ProviderServiceImplementation) UpdateLimit(
 	return connect.NewResponse(&payment.UpdateLimitResponse{}), nil
 }
 
+// starter:end
+
+// starter:begin ledger
 func (s *ProviderServiceImplementation) AppendLedgerEntries(
 	ctx context.Context, req *connect.Request[payment.AppendLedgerEntriesRequest],
 ) (*connect.Response[payment.AppendLedgerEntriesResponse], error) {
@@ -70,11 +88,12 @@ func (s *ProviderServiceImplementation) AppendLedgerEntries(
 	return connect.NewResponse(&payment.AppendLedgerEntriesResponse{}), nil
 }
 
+// starter:end
+
+// starter:begin aml
 func (s *ProviderServiceImplementation) ApprovePaymentQuotes(ctx context.Context, c *connect.Request[payment.ApprovePaymentQuoteRequest]) (*connect.Response[payment.ApprovePaymentQuoteResponse], error) {
 	//TODO: this is the endpoint to have a last look at quote and approve after AML check is done
 	return connect.NewResponse(&payment.ApprovePaymentQuoteResponse{}), nil
 }
 
-func ref(s string) *string {
-	return &s
-}
+// starter:end
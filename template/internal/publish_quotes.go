@@ -1,3 +1,5 @@
+// starter:feature payout payin
+
 package internal
 
 import (
@@ -25,8 +27,6 @@ func PublishQuotes(ctx context.Context, networkClient paymentconnect.NetworkServ
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			currency := "EUR"
-			paymentMethod := common.PaymentMethodType_PAYMENT_METHOD_TYPE_SEPA
 			expiration := timestamppb.New(time.Now().Add(30 * time.Second)) // expiration time - 30 seconds from now
 			timestamp := timestamppb.New(time.Now())                        // current timestamp
 
@@ -34,11 +34,12 @@ func PublishQuotes(ctx context.Context, networkClient paymentconnect.NetworkServ
 			// So if you want to publish multiple quotes, you need to combine them into a single request.
 			// Otherwise, if you send multiple requests, only the quotes from the last one will be available.
 			_, err := networkClient.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{
+				// starter:begin payout
 				PayOut: []*payment.UpdateQuoteRequest_Quote{ // The quote at which you want to take USDT and pay out local currency (off-ramp)
 					{
-						Currency:      currency,
+						Currency:      quoteCurrency,
 						QuoteType:     payment.QuoteType_QUOTE_TYPE_REALTIME, // REALTIME is only supported right now
-						PaymentMethod: paymentMethod,
+						PaymentMethod: quotePaymentMethod,
 						Expiration:    expiration,
 						Timestamp:     timestamp,
 						Bands: []*payment.UpdateQuoteRequest_Quote_Band{ // one or more bands are allowed
@@ -57,11 +58,13 @@ func PublishQuotes(ctx context.Context, networkClient paymentconnect.NetworkServ
 						},
 					},
 				},
+				// starter:end
+				// starter:begin payin
 				PayIn: []*payment.UpdateQuoteRequest_Quote{ // The quote at which you want to take local currency and settle with USDT (on-ramp)
 					{
-						Currency:      currency,
+						Currency:      quoteCurrency,
 						QuoteType:     payment.QuoteType_QUOTE_TYPE_REALTIME, // REALTIME is only supported right now
-						PaymentMethod: paymentMethod,
+						PaymentMethod: quotePaymentMethod,
 						Expiration:    expiration,
 						Timestamp:     timestamp,
 						Bands: []*payment.UpdateQuoteRequest_Quote_Band{ // one or more bands are allowed
@@ -80,6 +83,7 @@ func PublishQuotes(ctx context.Context, networkClient paymentconnect.NetworkServ
 						},
 					},
 				},
+				// starter:end
 			}))
 			if err != nil {
 				log.Printf("Error updating quote: %s\n", err.Error()) // handle errors appropriately
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/t-0-network/provider-starter-go/internal/merge"
+)
+
+// cmdUpgrade implements the upgrade command, which merges the changes made
+// to the template since a module was scaffolded into the module.
+func cmdUpgrade(args []string) {
+	flags := flag.NewFlagSet("upgrade", flag.ExitOnError)
+	templateSpec := flags.String("template", "", "template `module[@version]` or local directory to upgrade to (default the module in "+manifestFile+")")
+	version := flags.String("version", "", "template module `version` to upgrade to (default latest)")
+	baseSpec := flags.String("base", "", "template `module@version` or local directory the module was scaffolded from (default the version in "+manifestFile+")")
+	dryRun := flags.Bool("dry-run", false, "report the changes that would be made, without writing anything")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go run github.com/t-0-network/provider-starter-go@latest upgrade [flags] [dir]\n")
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	m, err := readManifest(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *baseSpec == "" {
+		if m.Template.Version == "" {
+			log.Fatalf("%s does not record a template version, because the module was scaffolded from a local directory or the embedded template; use -base to name the template it was scaffolded from", manifestFile)
+		}
+		*baseSpec = m.Template.Module + "@" + m.Template.Version
+	}
+	if *templateSpec == "" {
+		*templateSpec = m.Template.Module
+	}
+	oldSrc, err := loadTemplate(*baseSpec, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	newSrc, err := loadTemplate(*templateSpec, *version)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if oldSrc.mod == newSrc.mod && oldSrc.version != "" && oldSrc.version == newSrc.version {
+		log.Printf("%s is already at %s %s", dir, newSrc.mod, newSrc.version)
+		return
+	}
+
+	u := &upgrader{
+		dir:    dir,
+		dryRun: *dryRun,
+		labels: merge.Labels{
+			Base:   "template " + sourceName(oldSrc),
+			Ours:   "local",
+			Theirs: "template " + sourceName(newSrc),
+		},
+	}
+	if err := u.upgrade(m, oldSrc, newSrc); err != nil {
+		log.Fatal(err)
+	}
+	if u.dryRun {
+		return
+	}
+
+	m.Template = manifestTemplate{Module: newSrc.mod, Version: newSrc.version, Sum: newSrc.sum}
+	m.CLIVersion = cliVersion()
+	if err := writeManifest(dir, m); err != nil {
+		log.Fatal(err)
+	}
+	if u.conflicts > 0 {
+		log.Fatalf("upgraded %s to %s with conflicts in %d files; resolve the conflict markers and .new files, and review the changes", dir, sourceName(newSrc), u.conflicts)
+	}
+	log.Printf("upgraded %s to %s", dir, sourceName(newSrc))
+}
+
+// sourceName returns a name for src for use in messages.
+func sourceName(src *source) string {
+	switch {
+	case src.version == "" && src.dir == "":
+		return src.mod + " embedded in this tool"
+	case src.version == "":
+		return src.mod + " from " + src.dir
+	}
+	return src.mod + "@" + src.version
+}
+
+// An upgrader merges template changes into the files of a module.
+type upgrader struct {
+	dir       string
+	dryRun    bool
+	labels    merge.Labels
+	conflicts int // number of files merged with conflicts
+}
+
+// upgrade renders the old and new template sources the same way the module
+// described by m was scaffolded, and applies the differences between them to
+// the module's files, reporting each change made.
+func (u *upgrader) upgrade(m *manifest, oldSrc, newSrc *source) error {
+	modes := make(map[string]fs.FileMode) // permissions of the files of the new template
+	render := func(src *source) (map[string][]byte, error) {
+		files := make(map[string][]byte)
+		sc := &scaffold{srcMod: src.mod, fsys: src.fsys, dstMod: m.Module, vars: m.Vars}
+		err := sc.walk(func(f *templateFile) error {
+			if m.ParentModule != "" && (f.rel == "go.mod" || f.rel == "go.sum") {
+				// Merged into the parent module's, not tracked.
+				return nil
+			}
+			if f.link != "" {
+				// Symbolic links are not tracked.
+				return nil
+			}
+			data := f.data
+			if f.binary {
+				r, err := f.open()
+				if err != nil {
+					return err
+				}
+				defer r.Close()
+				if data, err = io.ReadAll(r); err != nil {
+					return err
+				}
+			}
+			files[f.rel] = data
+			modes[f.rel] = f.mode
+			return nil
+		})
+		return files, err
+	}
+	oldFiles, err := render(oldSrc)
+	if err != nil {
+		return err
+	}
+	newFiles, err := render(newSrc)
+	if err != nil {
+		return err
+	}
+
+	// From now on, the files generated from the new template
+	// are the ones verify compares against.
+	m.Files = make(map[string]string)
+	for name, data := range newFiles {
+		m.addFile(name, data)
+	}
+
+	names := slices.Sorted(maps.Keys(newFiles))
+	for name := range oldFiles {
+		if _, ok := newFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		base, inBase := oldFiles[name]
+		theirs, inTheirs := newFiles[name]
+		if inBase && inTheirs && bytes.Equal(base, theirs) {
+			continue // unchanged in the template
+		}
+
+		file := filepath.Join(u.dir, name)
+		ours, err := os.ReadFile(file)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		switch {
+		case !inTheirs:
+			// Removed from the template.
+			if !exists {
+				continue
+			}
+			if !bytes.Equal(ours, base) {
+				log.Printf("kept %s: removed from the template, but modified locally", name)
+				continue
+			}
+			log.Printf("removed %s", name)
+			if !u.dryRun {
+				if err := os.Remove(file); err != nil {
+					return err
+				}
+			}
+
+		case !exists && inBase:
+			log.Printf("skipped %s: changed in the template, but deleted locally", name)
+
+		case !exists:
+			log.Printf("added %s", name)
+			if err := u.write(file, theirs, modes[name]); err != nil {
+				return err
+			}
+
+		case bytes.Equal(ours, theirs):
+			// Already up to date.
+
+		case !isText(base) || !isText(ours) || !isText(theirs):
+			// Binary files cannot be merged.
+			if bytes.Equal(ours, base) {
+				log.Printf("updated %s", name)
+				if err := u.write(file, theirs, modes[name]); err != nil {
+					return err
+				}
+				continue
+			}
+			u.conflicts++
+			log.Printf("kept %s: binary file changed both locally and in the template; template version written to %s.new", name, name)
+			if err := u.write(file+".new", theirs, modes[name]); err != nil {
+				return err
+			}
+
+		default:
+			// A file added to the template that also exists locally
+			// is merged as if both had started out empty.
+			merged, n := merge.Merge(base, ours, theirs, u.labels)
+			if n > 0 {
+				u.conflicts++
+				log.Printf("merged %s with %d conflicts", name, n)
+			} else {
+				log.Printf("updated %s", name)
+			}
+			if err := u.write(file, merged, modes[name]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// write writes data to file, creating it with permissions perm,
+// unless this is a dry run.
+func (u *upgrader) write(file string, data []byte, perm fs.FileMode) error {
+	if u.dryRun {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		return err
+	}
+	return writeFile(file, &templateFile{data: data, mode: perm})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/t-0-network/provider-starter-go/internal/merge"
+)
+
+var upgradeTests = []struct {
+	name      string
+	file      string
+	base      string // contents in the old template; "-" if absent
+	ours      string // contents in the module; "-" if absent
+	theirs    string // contents in the new template; "-" if absent
+	want      string // contents in the module after upgrading; "-" if absent
+	wantNew   string // contents of the .new file; "-" if absent
+	conflicts int
+}{
+	{
+		name:   "clean",
+		file:   "notes.txt",
+		base:   "a\nb\nc\n",
+		ours:   "a\nB\nc\n",
+		theirs: "a\nb\nc\nd\n",
+		want:   "a\nB\nc\nd\n",
+	},
+	{
+		name:   "unmodified",
+		file:   "notes.txt",
+		base:   "a\n",
+		ours:   "a\n",
+		theirs: "b\n",
+		want:   "b\n",
+	},
+	{
+		name:      "conflict",
+		file:      "notes.txt",
+		base:      "a\nb\nc\n",
+		ours:      "a\nX\nc\n",
+		theirs:    "a\nY\nc\n",
+		want:      "a\n<<<<<<< local\nX\n||||||| old\nb\n=======\nY\n>>>>>>> new\nc\n",
+		conflicts: 1,
+	},
+	{
+		name:   "added",
+		file:   "notes.txt",
+		base:   "-",
+		ours:   "-",
+		theirs: "a\n",
+		want:   "a\n",
+	},
+	{
+		name:   "removed",
+		file:   "notes.txt",
+		base:   "a\n",
+		ours:   "a\n",
+		theirs: "-",
+		want:   "-",
+	},
+	{
+		name:   "removed but modified",
+		file:   "notes.txt",
+		base:   "a\n",
+		ours:   "b\n",
+		theirs: "-",
+		want:   "b\n",
+	},
+	{
+		name:   "deleted locally",
+		file:   "notes.txt",
+		base:   "a\n",
+		ours:   "-",
+		theirs: "b\n",
+		want:   "-",
+	},
+	{
+		name:   "binary",
+		file:   "logo.bin",
+		base:   "\x00a",
+		ours:   "\x00a",
+		theirs: "\x00b",
+		want:   "\x00b",
+	},
+	{
+		name:      "binary conflict",
+		file:      "logo.bin",
+		base:      "\x00a",
+		ours:      "\x00c",
+		theirs:    "\x00b",
+		want:      "\x00c",
+		wantNew:   "\x00b",
+		conflicts: 1,
+	},
+}
+
+// testSource returns a template module example.com/tmpl
+// holding file with the given contents, unless data is "-".
+func testSource(file, data string) *source {
+	fsys := fstest.MapFS{
+		"go.mod": {Data: []byte("module example.com/tmpl\n\ngo 1.25\n")},
+	}
+	if data != "-" {
+		fsys[file] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return &source{mod: "example.com/tmpl", fsys: fsys}
+}
+
+func TestUpgrade(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	for _, tt := range upgradeTests {
+		dir := t.TempDir()
+		file := filepath.Join(dir, tt.file)
+		if tt.ours != "-" {
+			if err := os.WriteFile(file, []byte(tt.ours), 0o666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		u := &upgrader{dir: dir, labels: merge.Labels{Base: "old", Ours: "local", Theirs: "new"}}
+		m := &manifest{Module: "example.com/acme/payout", Vars: map[string]string{"PackageName": "payout"}}
+		if err := u.upgrade(m, testSource(tt.file, tt.base), testSource(tt.file, tt.theirs)); err != nil {
+			t.Errorf("%s: upgrade: %v", tt.name, err)
+			continue
+		}
+		if got := readTestFile(t, file); got != tt.want {
+			t.Errorf("%s: %s = %q, want %q", tt.name, tt.file, got, tt.want)
+		}
+		if got := readTestFile(t, file+".new"); got != orNone(tt.wantNew) {
+			t.Errorf("%s: %s.new = %q, want %q", tt.name, tt.file, got, orNone(tt.wantNew))
+		}
+		if u.conflicts != tt.conflicts {
+			t.Errorf("%s: %d conflicts, want %d", tt.name, u.conflicts, tt.conflicts)
+		}
+		if _, ok := m.Files[tt.file]; ok != (tt.theirs != "-") {
+			t.Errorf("%s: manifest lists %s: %v, want %v", tt.name, tt.file, ok, !ok)
+		}
+	}
+}
+
+// readTestFile returns the contents of file, or "-" if it does not exist.
+func readTestFile(t *testing.T, file string) string {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "-"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// orNone returns s, or "-" if s is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// wizard prompts for the scaffold settings on the terminal.
+// Each answer is checked the same way as the corresponding flag or argument,
+// and the question is asked again until the answer is valid.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// run asks for the settings, offering the current flag values as defaults,
+// and stores the answers in the flags. It returns the command-line
+// arguments matching the answers, and the imported private key, if any.
+// If askKey is false, a key was already given and run does not ask for one.
+func (w *wizard) run(askKey bool) (args []string, key *ecdsa.PrivateKey, err error) {
+	fmt.Fprintf(w.out, "Creating a new t-0 Network provider service.\n\n")
+
+	dstMod, err := w.ask("Module path (for example github.com/acme/provider)", "", func(s string) error {
+		err := checkModulePath(s)
+		if err != nil {
+			if s := suggestModulePath(s); s != "" {
+				err = fmt.Errorf("%v; did you mean %q?", err, s)
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defDir := "./" + projectName(dstMod)
+	if *dirFlag != "" {
+		defDir = *dirFlag
+	}
+	dir, err := w.ask("Target directory", defDir, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	*dirFlag = dir
+
+	for _, q := range []struct {
+		prompt string
+		flag   string
+		check  func(string) error
+	}{
+		{"Pay-out currency", "currency", checkCurrency},
+		{"Pay-out payment method (for example SEPA, SWIFT or PIX)", "payment-method", checkPaymentMethod},
+		{"Port", "port", checkPort},
+		{"t-0 Network endpoint (sandbox, production or a URL)", "endpoint", checkEndpoint},
+		{"Features (payout, payin, quotes, ledger, limits, aml, or all)", "features", func(s string) error {
+			_, err := parseFeatures(s)
+			return err
+		}},
+	} {
+		f := flag.Lookup(q.flag)
+		answer, err := w.ask(q.prompt, f.Value.String(), q.check)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := f.Value.Set(answer); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	yesNo := func(s string) error {
+		if s != "yes" && s != "no" {
+			return errors.New(`answer "yes" or "no"`)
+		}
+		return nil
+	}
+	def := "no"
+	if *keystoreFlag {
+		def = "yes"
+	}
+	answer, err := w.ask("Store the private key in an encrypted keystore instead of .env (yes or no)", def, yesNo)
+	if err != nil {
+		return nil, nil, err
+	}
+	*keystoreFlag = answer == "yes"
+
+	if !askKey {
+		fmt.Fprintln(w.out)
+		return []string{dstMod}, nil, nil
+	}
+	keyCheck := func(s string) error {
+		if s != "generate" && s != "import" {
+			return errors.New(`answer "generate" or "import"`)
+		}
+		return nil
+	}
+	answer, err = w.ask("Generate a new key pair or import an existing private key (generate or import)", "generate", keyCheck)
+	if err != nil {
+		return nil, nil, err
+	}
+	if answer == "import" {
+		for key == nil {
+			fmt.Fprintf(w.out, "Private key (hex, input hidden): ")
+			hexKey, err := w.secret()
+			if err != nil {
+				return nil, nil, err
+			}
+			if key, err = parsePrivateKey(hexKey); err != nil {
+				fmt.Fprintf(w.out, "  %v\n", err)
+			}
+		}
+	}
+	fmt.Fprintln(w.out)
+	return []string{dstMod}, key, nil
+}
+
+// ask prints prompt, offering def as the default answer, and returns the answer.
+// If check is not nil, ask repeats the question until check accepts the answer.
+func (w *wizard) ask(prompt, def string, check func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.out, "%s [%s]: ", prompt, def)
+		} else {
+			fmt.Fprintf(w.out, "%s: ", prompt)
+		}
+		line, err := w.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" {
+			fmt.Fprintf(w.out, "  an answer is required\n")
+			continue
+		}
+		if check != nil {
+			if err := check(answer); err != nil {
+				fmt.Fprintf(w.out, "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// secret reads a line without echoing it, if the input is a terminal.
+func (w *wizard) secret() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := w.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(w.out)
+	return strings.TrimSpace(string(b)), err
+}
+
+// isTerminal reports whether both standard input and standard error
+// are terminals, so that the wizard can interact with the user.
+func isTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/version"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// A layout says how the new module relates to a module or workspace
+// enclosing the target directory, selected by -layout.
+type layout string
+
+const (
+	layoutAuto      layout = "auto"      // layoutWorkspace in a workspace, layoutModule otherwise
+	layoutModule    layout = "module"    // a module of its own, nested in any enclosing one
+	layoutWorkspace layout = "workspace" // a module of its own, added to the enclosing go.work
+	layoutPackage   layout = "package"   // packages of the enclosing module
+)
+
+func parseLayout(s string) (layout, error) {
+	switch l := layout(s); l {
+	case layoutAuto, layoutModule, layoutWorkspace, layoutPackage:
+		return l, nil
+	}
+	return "", fmt.Errorf("invalid -layout %q; want auto, module, workspace or package", s)
+}
+
+// A parent describes the module and workspace enclosing a target directory.
+type parent struct {
+	modDir   string // directory of the enclosing module, or ""
+	mod      string // module path of the enclosing module
+	workFile string // enclosing go.work file, or ""
+}
+
+// findParent returns the module and workspace enclosing dir,
+// which need not exist yet. A go.mod file in dir itself is ignored,
+// as scaffolding replaces it. Like the go command, findParent uses
+// the go.work file named by $GOWORK, if set, and otherwise the nearest
+// one in an ancestor directory.
+func findParent(dir string) (*parent, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := new(parent)
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+	case "":
+		p.workFile = findUp(filepath.Dir(abs), "go.work")
+	default:
+		p.workFile = gowork
+	}
+	if gomod := findUp(filepath.Dir(abs), "go.mod"); gomod != "" {
+		data, err := os.ReadFile(gomod)
+		if err != nil {
+			return nil, err
+		}
+		p.modDir, p.mod = filepath.Dir(gomod), modfile.ModulePath(data)
+		if p.mod == "" {
+			return nil, fmt.Errorf("%s has no module statement", gomod)
+		}
+	}
+	return p, nil
+}
+
+// findUp returns the file with the given name in dir or its nearest
+// ancestor having one, or "" if there is none.
+func findUp(dir, name string) string {
+	for {
+		file := filepath.Join(dir, name)
+		if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+			return file
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// resolve returns the layout to use for the target directory dir:
+// l, unless it is layoutAuto. It reports an error if the layout
+// requires an enclosing module or workspace that p lacks.
+func (p *parent) resolve(l layout, dir string) (layout, error) {
+	switch l {
+	case layoutAuto:
+		if p.workFile != "" {
+			return layoutWorkspace, nil
+		}
+		return layoutModule, nil
+	case layoutWorkspace:
+		if p.workFile == "" {
+			return "", fmt.Errorf("-layout workspace: %s is not in a go.work workspace", dir)
+		}
+	case layoutPackage:
+		if p.mod == "" {
+			return "", fmt.Errorf("-layout package: %s is not in a module", dir)
+		}
+	}
+	return l, nil
+}
+
+// importPath returns the import path of the package in dir,
+// which must be in the enclosing module.
+func (p *parent) importPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(p.modDir, abs)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", fmt.Errorf("%s is the root of module %s, not a package directory in it", dir, p.mod)
+	}
+	return path.Join(p.mod, filepath.ToSlash(rel)), nil
+}
+
+// mergeRequirements returns the go.mod file parentMod, named file,
+// with the requirements of the template's go.mod file tmplMod added to it.
+// A module required by both is required at the higher version, and the
+// go version is raised to the template's if that is higher. Running
+// go mod tidy afterwards corrects the // indirect comments.
+// The template's replace directives for modules the parent does not
+// replace are added too, with local directories, relative to tmplDir,
+// made relative to the parent module's directory.
+func mergeRequirements(file string, parentMod, tmplMod []byte, tmplDir string) ([]byte, error) {
+	pf, err := modfile.Parse(file, parentMod, nil)
+	if err != nil {
+		return nil, err
+	}
+	tf, err := modfile.Parse("go.mod", tmplMod, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template go.mod:\n%s", err)
+	}
+	if tf.Go != nil && (pf.Go == nil || version.Compare("go"+tf.Go.Version, "go"+pf.Go.Version) > 0) {
+		if err := pf.AddGoStmt(tf.Go.Version); err != nil {
+			return nil, err
+		}
+	}
+	have := make(map[string]string)
+	for _, r := range pf.Require {
+		have[r.Mod.Path] = r.Mod.Version
+	}
+	for _, r := range tf.Require {
+		old, ok := have[r.Mod.Path]
+		switch {
+		case !ok:
+			pf.AddNewRequire(r.Mod.Path, r.Mod.Version, r.Indirect)
+		case semver.Compare(r.Mod.Version, old) > 0:
+			if err := pf.AddRequire(r.Mod.Path, r.Mod.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, r := range tf.Replace {
+		if slices.ContainsFunc(pf.Replace, func(pr *modfile.Replace) bool { return pr.Old.Path == r.Old.Path }) {
+			continue
+		}
+		newPath := r.New.Path
+		if modfile.IsDirectoryPath(newPath) && !filepath.IsAbs(newPath) {
+			if newPath, err = relativeTo(filepath.Dir(file), filepath.Join(tmplDir, newPath)); err != nil {
+				return nil, err
+			}
+		}
+		if err := pf.AddReplace(r.Old.Path, r.Old.Version, newPath, r.New.Version); err != nil {
+			return nil, err
+		}
+	}
+	pf.Cleanup()
+	return pf.Format()
+}
+
+// mergeSum returns the go.sum file parentSum with the lines of the
+// template's go.sum file tmplSum added to it, in sorted order.
+func mergeSum(parentSum, tmplSum []byte) []byte {
+	var lines []string
+	for _, data := range [][]byte{parentSum, tmplSum} {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	slices.Sort(lines)
+	lines = slices.Compact(lines)
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// mergeIntoParent merges the template's go.mod and go.sum files,
+// with contents gomod and gosum, for the new packages in dir,
+// into those of the enclosing module, returning the names of the files
+// it changed. If dryRun is set, mergeIntoParent only reports them.
+func (p *parent) mergeIntoParent(dir string, gomod, gosum []byte, dryRun bool) ([]string, error) {
+	var changed []string
+	for _, f := range []struct {
+		name  string
+		merge func(old []byte) ([]byte, error)
+	}{
+		{"go.mod", func(old []byte) ([]byte, error) {
+			return mergeRequirements(filepath.Join(p.modDir, "go.mod"), old, gomod, dir)
+		}},
+		{"go.sum", func(old []byte) ([]byte, error) { return mergeSum(old, gosum), nil }},
+	} {
+		file := filepath.Join(p.modDir, f.name)
+		old, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		data, err := f.merge(old)
+		if err != nil {
+			return nil, withKind(errParse, err)
+		}
+		if bytes.Equal(old, data) {
+			continue
+		}
+		changed = append(changed, file)
+		if !dryRun {
+			if err := os.WriteFile(file, data, 0666); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+// addUse adds a use directive for the module in dir to the enclosing
+// go.work file, unless it already has one, raising the go version of the
+// workspace to goVersion if that is higher. It reports whether it added
+// a use directive. If dryRun is set, addUse only reports whether it would.
+func (p *parent) addUse(dir, goVersion string, dryRun bool) (bool, error) {
+	data, err := os.ReadFile(p.workFile)
+	if err != nil {
+		return false, err
+	}
+	wf, err := modfile.ParseWork(p.workFile, data, nil)
+	if err != nil {
+		return false, withKind(errParse, err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(filepath.Dir(p.workFile), abs)
+	if err != nil {
+		return false, err
+	}
+	use := "./" + filepath.ToSlash(rel)
+	if strings.HasPrefix(rel, "..") {
+		use = filepath.ToSlash(abs)
+	}
+	for _, u := range wf.Use {
+		if path.Clean(u.Path) == path.Clean(use) {
+			return false, nil
+		}
+	}
+	if dryRun {
+		return true, nil
+	}
+	if err := wf.AddUse(use, ""); err != nil {
+		return false, err
+	}
+	if goVersion != "" && (wf.Go == nil || version.Compare("go"+goVersion, "go"+wf.Go.Version) > 0) {
+		if err := wf.AddGoStmt(goVersion); err != nil {
+			return false, err
+		}
+	}
+	wf.Cleanup()
+	return true, os.WriteFile(p.workFile, modfile.Format(wf.Syntax), 0666)
+}
+
+// A placement says where the files of the new module go.
+type placement struct {
+	dir    string // target directory
+	layout layout // resolved layout, never layoutAuto
+	parent *parent
+
+	gomod []byte // the template's go.mod file, once seen
+	gosum []byte // the template's go.sum file, once seen
+}
+
+// capture records the template's go.mod and go.sum files, f.
+// It reports whether f is to be merged into the enclosing module
+// instead of being written.
+func (pl *placement) capture(f *templateFile) bool {
+	switch f.rel {
+	case "go.mod":
+		pl.gomod = f.data
+	case "go.sum":
+		pl.gosum = f.data
+	default:
+		return false
+	}
+	return pl.layout == layoutPackage
+}
+
+// finish updates the enclosing module or workspace, as the layout
+// requires, after the files of the new module have been written.
+// It returns the files it changed, with paths relative to the target
+// directory. If dryRun is set, finish only reports the changes.
+func (pl *placement) finish(dryRun bool) ([]fileResult, error) {
+	var files []fileResult
+	add := func(file, action string) error {
+		abs, err := filepath.Abs(pl.dir)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(abs, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, fileResult{filepath.ToSlash(rel), action})
+		return nil
+	}
+	switch pl.layout {
+	case layoutPackage:
+		changed, err := pl.parent.mergeIntoParent(pl.dir, pl.gomod, pl.gosum, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range changed {
+			if err := add(file, "template requirements merged"); err != nil {
+				return nil, err
+			}
+		}
+	case layoutWorkspace:
+		var goVersion string
+		if f, err := modfile.ParseLax("go.mod", pl.gomod, nil); err == nil && f.Go != nil {
+			goVersion = f.Go.Version
+		}
+		added, err := pl.parent.addUse(pl.dir, goVersion, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			if err := add(pl.parent.workFile, "use directive added"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// placeModule returns the placement of the new module dstMod in dir,
+// with the layout selected by -layout. In the package layout,
+// dstMod must be the import path of dir in the enclosing module.
+func placeModule(dir, dstMod string) (*placement, error) {
+	l, err := parseLayout(*layoutFlag)
+	if err != nil {
+		return nil, err
+	}
+	p, err := findParent(dir)
+	if err != nil {
+		return nil, withKind(errParse, err)
+	}
+	if l, err = p.resolve(l, dir); err != nil {
+		return nil, err
+	}
+	if l == layoutPackage {
+		want, err := p.importPath(dir)
+		if err != nil {
+			return nil, err
+		}
+		if dstMod != want {
+			return nil, fmt.Errorf("-layout package: dstmod must be %s, the import path of %s in module %s", want, dir, p.mod)
+		}
+	}
+	return &placement{dir: dir, layout: l, parent: p}, nil
+}
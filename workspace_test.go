@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var mergeRequirementsTests = []struct {
+	name   string
+	tmplGo string // go version of the template, if not 1.25
+	parent string
+	want   string
+}{
+	{
+		name: "add",
+		parent: `module example.com/acme
+
+go 1.24
+`,
+		want: `module example.com/acme
+
+go 1.25
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0 // indirect
+)
+
+replace example.com/tools => ./payout/tools
+`,
+	},
+	{
+		name: "higher versions kept",
+		parent: `module example.com/acme
+
+go 1.26
+
+require example.com/a v1.3.0
+
+replace example.com/tools => ../tools
+`,
+		want: `module example.com/acme
+
+go 1.26
+
+require (
+	example.com/a v1.3.0
+	example.com/b v0.1.0 // indirect
+)
+
+replace example.com/tools => ../tools
+`,
+	},
+	{
+		name: "lower versions raised",
+		parent: `module example.com/acme
+
+go 1.25
+
+require (
+	example.com/a v1.1.0
+	example.com/b v0.0.1
+)
+`,
+		want: `module example.com/acme
+
+go 1.25
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0
+)
+
+replace example.com/tools => ./payout/tools
+`,
+	},
+	{
+		name:   "release candidate",
+		tmplGo: "1.26rc1",
+		parent: `module example.com/acme
+
+go 1.25
+`,
+		want: `module example.com/acme
+
+go 1.26rc1
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0 // indirect
+)
+
+replace example.com/tools => ./payout/tools
+`,
+	},
+	{
+		name:   "release above release candidate",
+		tmplGo: "1.26rc1",
+		parent: `module example.com/acme
+
+go 1.26.0
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0
+)
+
+replace example.com/tools => ../tools
+`,
+		want: `module example.com/acme
+
+go 1.26.0
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0
+)
+
+replace example.com/tools => ../tools
+`,
+	},
+}
+
+func TestMergeRequirements(t *testing.T) {
+	const tmpl = `module example.com/acme/payout
+
+go 1.25
+
+require (
+	example.com/a v1.2.0
+	example.com/b v0.1.0 // indirect
+)
+
+replace example.com/tools => ./tools
+`
+	file := filepath.Join("repo", "go.mod")
+	for _, tt := range mergeRequirementsTests {
+		tmplMod := tmpl
+		if tt.tmplGo != "" {
+			tmplMod = strings.Replace(tmpl, "go 1.25", "go "+tt.tmplGo, 1)
+		}
+		got, err := mergeRequirements(file, []byte(tt.parent), []byte(tmplMod), filepath.Join("repo", "payout"))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: mergeRequirements =\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+var mergeSumTests = []struct {
+	name         string
+	parent, tmpl string
+	want         string
+}{
+	{"empty parent", "", "b h1:b=\na h1:a=\n", "a h1:a=\nb h1:b=\n"},
+	{"shared lines", "a h1:a=\nc h1:c=\n", "a h1:a=\nb h1:b=\n", "a h1:a=\nb h1:b=\nc h1:c=\n"},
+	{"no final newline", "a h1:a=", "b h1:b=", "a h1:a=\nb h1:b=\n"},
+}
+
+func TestMergeSum(t *testing.T) {
+	for _, tt := range mergeSumTests {
+		if got := mergeSum([]byte(tt.parent), []byte(tt.tmpl)); string(got) != tt.want {
+			t.Errorf("%s: mergeSum = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}